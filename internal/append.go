@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"os"
+	"sync"
+)
+
+// appendMu keeps concurrent dependencies and commands from interleaving the
+// records they append.
+var appendMu sync.Mutex
+
+// AppendFile appends b to the file at path, creating it if needed. The
+// compiled magefile records timings, watched sources and the edges of the
+// dependency graph this way, one per line, for mage to read back once it
+// exits.
+func AppendFile(path string, b []byte) error {
+	defer appendMu.Unlock()
+	appendMu.Lock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Timing records a single run of a top level target, a single call to a
+// dependency through mg.Deps and friends, or a single command run by package
+// sh. The compiled magefile appends one JSON encoded Timing per line to the
+// file named by MAGEFILE_TIMINGS_FILE, and mage reads them back to print the
+// timings summary and to export traces.
+type Timing struct {
+	Name       string    `json:"name"`                 // fully qualified function name
+	ID         string    `json:"id,omitempty"`         // mg.Fn ID of a dependency
+	Target     string    `json:"target,omitempty"`     // target name, for top level targets
+	ParentName string    `json:"parentName,omitempty"` // function that declared the dependency or ran the command
+	ParentID   string    `json:"parentId,omitempty"`   // mg.Fn ID of the parent, if it is a dependency
+	Cached     bool      `json:"cached,omitempty"`     // the dependency had already been run
+	Failed     bool      `json:"failed,omitempty"`     // the target, dependency or command failed or panicked
+	Error      string    `json:"error,omitempty"`      // the error the target or dependency failed with
+	Cmd        string    `json:"cmd,omitempty"`        // command name, for commands
+	Args       []string  `json:"args,omitempty"`       // command arguments, only recorded if MAGEFILE_TRACE_ARGS is set
+	ExitCode   int       `json:"exitCode,omitempty"`   // exit code, for commands
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+// AppendTiming appends t to the timings file at path.
+func AppendTiming(path string, t Timing) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return AppendFile(path, append(b, '\n'))
+}
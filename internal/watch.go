@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AppendWatch appends the given files, directories and globs to the watch file
+// at path, one absolute path per line. The compiled magefile records the
+// sources passed to package target in the file named by MAGEFILE_WATCH_FILE,
+// and mage -watch reads them back to watch them.
+func AppendWatch(path string, sources ...string) error {
+	var b strings.Builder
+	for _, s := range sources {
+		abs, err := filepath.Abs(s)
+		if err != nil {
+			return err
+		}
+		_, _ = b.WriteString(abs + "\n")
+	}
+	return AppendFile(path, []byte(b.String()))
+}
+
+// ReadWatch returns the sources recorded in the watch file at path, without
+// duplicates, in the order they were first recorded.
+func ReadWatch(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var sources []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		sources = append(sources, s)
+	}
+	return sources, scanner.Err()
+}
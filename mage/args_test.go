@@ -2,6 +2,8 @@ package mage
 
 import (
 	"bytes"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -125,6 +127,50 @@ func TestBadFloat64Arg(t *testing.T) {
 	}
 }
 
+func TestSliceArgs(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/args",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"sum", "1,2,3", "test", "./mg,./sh", "test", ""},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log(stderr.String())
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "sum 6\ntesting 2 [./mg ./sh]\ntesting 0 []\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\n%q", actual)
+	}
+}
+
+func TestBadSliceArg(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/args",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"sum", "1,two"},
+	}
+	code := Invoke(inv)
+	if code != 2 {
+		t.Log("stderr:", stderr)
+		t.Log("stdout:", stdout)
+		t.Fatalf("expected code 2, but got %v", code)
+	}
+	actual := stderr.String()
+	expected := "can't convert argument \"1,two\" to []int\n"
+
+	if actual != expected {
+		t.Fatalf("output is not expected:\n%q", actual)
+	}
+}
+
 func TestMissingArgs(t *testing.T) {
 	stderr := &bytes.Buffer{}
 	stdout := &bytes.Buffer{}
@@ -753,3 +799,426 @@ func TestMgF(t *testing.T) {
 		t.Fatalf("output is not expected: %q", actual)
 	}
 }
+
+func TestMgFSliceAndFloat(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/args",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"HasSliceDep"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Log("stdout:", stdout)
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	actual := stdout.String()
+	for _, s := range []string{"testing 2 [./mg ./sh]\n", "1.5 * 2 = 3.0\n"} {
+		if !strings.Contains(actual, s) {
+			t.Fatalf("expected output to contain %q, but got %q", s, actual)
+		}
+	}
+}
+
+func TestOptionalSliceArg(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/optargs",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"tag", "v1", "-labels=a,b", "-labels=c", "tag", "v2"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "tag v1 a b c\ntag v2\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\n%q", actual)
+	}
+}
+
+func TestTextUnmarshalerArgs(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/textargs",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"build", "linux/amd64", "build", "darwin/arm64", "-level=info", "all"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "building for linux on amd64\nbuilding for darwin on arm64 with log level 1\ndeploying to linux/arm64\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\n%q", actual)
+	}
+}
+
+func TestBadTextUnmarshalerArg(t *testing.T) {
+	for _, args := range [][]string{{"build", "linux"}, {"build", "linux/amd64", "-level=loud"}} {
+		stderr := &bytes.Buffer{}
+		stdout := &bytes.Buffer{}
+		inv := Invocation{
+			Dir:    "./testdata/textargs",
+			Stderr: stderr,
+			Stdout: stdout,
+			Args:   args,
+		}
+		code := Invoke(inv)
+		if code != 2 {
+			t.Log("stderr:", stderr)
+			t.Fatalf("expected code 2 for %q, but got %v", args, code)
+		}
+	}
+}
+
+func TestTextUnmarshalerArgsHelp(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/textargs",
+		Stderr: stderr,
+		Stdout: stdout,
+		Help:   true,
+		Args:   []string{"build"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := `Build builds for a platform.
+
+Usage:
+
+	mage build <platform> [-level=<LogLevel>]
+
+Arguments:
+
+	<platform> Platform  the platform to build for
+
+`
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestEnumArgs(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/enum",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"deploy", "staging", "deploy", "prod", "-region=eu"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "deploying to staging\ndeploying to prod in eu\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\n%q", actual)
+	}
+}
+
+func TestBadEnumArg(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"deploy", "qa"}, "invalid value \"qa\" for argument \"env\" of target \"Deploy\", must be one of: dev, staging, prod\n"},
+		{[]string{"deploy", "dev", "-region=asia"}, "invalid value \"asia\" for argument \"region\" of target \"Deploy\", must be one of: us, eu\n"},
+	}
+	for _, tt := range tests {
+		stderr := &bytes.Buffer{}
+		stdout := &bytes.Buffer{}
+		inv := Invocation{
+			Dir:    "./testdata/enum",
+			Stderr: stderr,
+			Stdout: stdout,
+			Args:   tt.args,
+		}
+		code := Invoke(inv)
+		if code != 2 {
+			t.Log("stderr:", stderr)
+			t.Fatalf("expected code 2 for %q, but got %v", tt.args, code)
+		}
+		if actual := stderr.String(); actual != tt.expected {
+			t.Errorf("expected %q, but got %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestEnumArgsHelp(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/enum",
+		Stderr: stderr,
+		Stdout: stdout,
+		Help:   true,
+		Args:   []string{"deploy"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := `Deploy deploys to an environment.
+
+Usage:
+
+	mage deploy <env> [-region=<us|eu>]
+
+Arguments:
+
+	<env> dev|staging|prod  the environment to deploy to
+
+`
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestEnumArgsAutocomplete(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "deploy\nrelease\n"},
+		{[]string{"deploy"}, "dev\nstaging\nprod\n"},
+		{[]string{"-v", "release", "v1", "deploy"}, "dev\nstaging\nprod\n"},
+		{[]string{"release"}, ""},
+		{[]string{"deploy", "dev", "-region=us"}, "deploy\nrelease\n"},
+	}
+	for _, tt := range tests {
+		stdout := &bytes.Buffer{}
+		inv := Invocation{
+			Dir:          "./testdata/enum",
+			Stderr:       io.Discard,
+			Stdout:       stdout,
+			Autocomplete: true,
+			Args:         tt.args,
+		}
+		code := Invoke(inv)
+		if code != 0 {
+			t.Fatalf("expected code 0 for %q, but got %v", tt.args, code)
+		}
+		if actual := stdout.String(); actual != tt.expected {
+			t.Errorf("expected %q to complete to %q, but got %q", tt.args, tt.expected, actual)
+		}
+	}
+}
+
+func TestEnumArgsAutocompleteHashFast(t *testing.T) {
+	// compile the magefile, so there's a binary mage could run.
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/enum",
+		Stderr: io.Discard,
+		Stdout: stdout,
+		Args:   []string{"release", "v1"},
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+
+	// completing the words of a command must not run it.
+	stdout.Reset()
+	inv.HashFast = true
+	inv.Autocomplete = true
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	expected := "deploy\nrelease\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestOptionsStruct(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/options",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"deploy", "prod", "-region=eu", "-dryrun", "-tags=a", "-tags=b", "deploy", "dev", "-replicas=5", "staging"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := `deploying to prod in "eu" with 3 replicas, dry run true, tags a,b
+deploying to dev in "" with 5 replicas, dry run false, tags 
+deploying to staging in "us" with 1 replicas, dry run false, tags 
+`
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestBadOptionsStructArg(t *testing.T) {
+	for _, args := range [][]string{{"deploy", "prod", "-replicas=many"}, {"deploy", "prod", "-internal=x"}} {
+		stderr := &bytes.Buffer{}
+		stdout := &bytes.Buffer{}
+		inv := Invocation{
+			Dir:    "./testdata/options",
+			Stderr: stderr,
+			Stdout: stdout,
+			Args:   args,
+		}
+		code := Invoke(inv)
+		if code != 2 {
+			t.Log("stderr:", stderr)
+			t.Fatalf("expected code 2 for %q, but got %v", args, code)
+		}
+	}
+}
+
+func TestOptionsStructHelp(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/options",
+		Stderr: stderr,
+		Stdout: stdout,
+		Help:   true,
+		Args:   []string{"deploy"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := `Deploy deploys to an environment.
+
+Usage:
+
+	mage deploy <env> [<flags>]
+
+Flags:
+
+	-region=<string>  the region to deploy to
+	-replicas=<int>   how many replicas to run (default: 3)
+	-dryRun=<bool>    only print what would be done
+	-tags=<[]string>  tags for the release
+
+`
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestArgDefaults(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/defaults",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"greet", "bob", "greet", "alice", "-count=1", "-greeting=Hi", "-names=eve"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "Hello there, bob! x3 after 1ms\nHi, alice, eve! x1 after 1ms\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestArgEnv(t *testing.T) {
+	t.Setenv("GREET_COUNT", "2")
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/defaults",
+		Stderr: stderr,
+		Stdout: stdout,
+		Args:   []string{"greet", "bob", "greet", "alice", "-count=1"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := "Hello there, bob! x2 after 1ms\nHello there, alice! x1 after 1ms\n"
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestBadArgEnv(t *testing.T) {
+	t.Setenv("GREET_COUNT", "lots")
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/defaults",
+		Stderr: stderr,
+		Stdout: &bytes.Buffer{},
+		Args:   []string{"greet", "bob"},
+	}
+	code := Invoke(inv)
+	if code != 2 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected code 2, but got %v", code)
+	}
+}
+
+func TestArgDefaultsHelp(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/defaults",
+		Stderr: stderr,
+		Stdout: stdout,
+		Help:   true,
+		Args:   []string{"greet"},
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Log("stderr:", stderr)
+		t.Fatalf("expected code 0, but got %v", code)
+	}
+	actual := stdout.String()
+	expected := `Greet greets someone a number of times.
+
+Usage:
+
+	mage greet <name> [<flags>]
+
+Flags:
+
+	-count=<int>           how many times to greet (env: $GREET_COUNT, default: 3)
+	-greeting=<string>     (default: Hello there)
+	-wait=<time.Duration>  (default: 1ms)
+	-names=<[]string>      more people to greet
+
+`
+	if actual != expected {
+		t.Fatalf("output is not expected:\ngot:  %q\nwant: %q", actual, expected)
+	}
+}
@@ -296,12 +296,12 @@ func bashCompletionScript(mageBin string) string {
 	return `_mage_completions() {
     local cur="${COMP_WORDS[COMP_CWORD]}"
     if [[ "$cur" == -* ]]; then
-        local flags="-l -h -v -f -debug -t -d -w -keep -compile -clean -init -version -gocmd -goos -goarch -ldflags -autocomplete -install -multiline"
+        local flags="-l -h -v -f -debug -t -d -w -keep -compile -clean -init -version -gocmd -goos -goarch -ldflags -autocomplete -install -multiline -graph -timings -trace -j -failfast -k -json -docs -watch"
         COMPREPLY=($(compgen -W "$flags" -- "$cur"))
         return
     fi
     local IFS=$'\n'
-    COMPREPLY=($(compgen -W "$('` + mageBin + `' -autocomplete 2>/dev/null)" -- "$cur"))
+    COMPREPLY=($(compgen -W "$('` + mageBin + `' -autocomplete -- "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)" -- "$cur"))
 }
 complete -F _mage_completions mage
 `
@@ -335,11 +335,20 @@ _mage() {
             '-autocomplete:print target names for shell completion'
             '-install:install shell completion for the given shell'
             '-multiline:retain line returns in help text'
+            '-graph:write the dependency graph of the targets run to a file'
+            '-timings:print how long each target and dependency took'
+            '-trace:export a trace of the targets run to a file or endpoint'
+            '-j:run at most this many dependencies at the same time'
+            '-failfast:cancel the remaining dependencies as soon as one fails'
+            '-k:keep going and run all targets even if some fail'
+            '-json:print the output of -l or -autocomplete as JSON'
+            '-docs:print documentation for the targets as markdown or man'
+            '-watch:run the targets again whenever the files they depend on change'
         )
         _describe 'flag' flags
         return
     fi
-    targets=(${(f)"$('` + mageBin + `' -autocomplete 2>/dev/null)"})
+    targets=(${(f)"$('` + mageBin + `' -autocomplete -- ${words[2,CURRENT-1]} 2>/dev/null)"})
     _describe 'target' targets
 }
 if (( $+functions[compdef] )); then
@@ -355,7 +364,7 @@ fi
 func fishCompletionScript(mageBin string) string {
 	return `# mage tab completion for fish
 complete -c mage -f
-complete -c mage -a '('` + mageBin + `' -autocomplete 2>/dev/null)' -d 'mage target'
+complete -c mage -a '('` + mageBin + `' -autocomplete -- (commandline -opc)[2..-1] 2>/dev/null)' -d 'mage target'
 complete -c mage -s l -d 'list mage targets in this directory'
 complete -c mage -s h -d 'show this help'
 complete -c mage -s v -d 'show verbose output when running mage targets'
@@ -376,6 +385,15 @@ complete -c mage -l ldflags -r -d 'set ldflags for binary produced with -compile
 complete -c mage -l autocomplete -d 'print target names for shell completion'
 complete -c mage -l install -r -a 'bash zsh fish powershell pwsh' -d 'install shell completion'
 complete -c mage -l multiline -d 'retain line returns in help text'
+complete -c mage -l graph -r -F -d 'write the dependency graph of the targets run to a file'
+complete -c mage -l timings -d 'print how long each target and dependency took'
+complete -c mage -l trace -r -F -d 'export a trace of the targets run to a file or endpoint'
+complete -c mage -s j -r -d 'run at most this many dependencies at the same time'
+complete -c mage -l failfast -d 'cancel the remaining dependencies as soon as one fails'
+complete -c mage -s k -d 'keep going and run all targets even if some fail'
+complete -c mage -l json -d 'print the output of -l or -autocomplete as JSON'
+complete -c mage -l docs -r -a 'markdown man' -d 'print documentation for the targets'
+complete -c mage -l watch -d 'run the targets again whenever the files they depend on change'
 `
 }
 
@@ -405,13 +423,24 @@ Register-ArgumentCompleter -CommandName mage -ScriptBlock {
             @{N='-ldflags'; D='set ldflags for -compile'},
             @{N='-autocomplete'; D='print target names for shell completion'},
             @{N='-install'; D='install shell completion'},
-            @{N='-multiline'; D='retain line returns in help text'}
+            @{N='-multiline'; D='retain line returns in help text'},
+            @{N='-graph'; D='write the dependency graph to a file'},
+            @{N='-timings'; D='print how long each target and dependency took'},
+            @{N='-trace'; D='export a trace of the targets run to a file or endpoint'},
+            @{N='-j'; D='run at most this many dependencies at the same time'},
+            @{N='-failfast'; D='cancel the remaining dependencies as soon as one fails'},
+            @{N='-k'; D='keep going and run all targets even if some fail'},
+            @{N='-json'; D='print the output of -l or -autocomplete as JSON'},
+            @{N='-docs'; D='print documentation for the targets as markdown or man'},
+            @{N='-watch'; D='run the targets again whenever the files they depend on change'}
         )
         $flags | Where-Object { $_.N -like "$wordToComplete*" } | ForEach-Object {
             [System.Management.Automation.CompletionResult]::new($_.N, $_.N, 'ParameterValue', $_.D)
         }
     } else {
-        (& '` + mageBin + `' -autocomplete 2>$null) -split "` + "`n" + `" |
+        $words = @($commandAst.CommandElements | Select-Object -Skip 1 |
+            Where-Object { $_.Extent.EndOffset -lt $cursorPosition } | ForEach-Object { $_.ToString() })
+        (& '` + mageBin + `' -autocomplete '--' @words 2>$null) -split "` + "`n" + `" |
             Where-Object { $_ -ne '' -and $_ -like "$wordToComplete*" } |
             ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
@@ -0,0 +1,252 @@
+package mage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/magefile/mage/parse"
+)
+
+// docsFormats are the formats mage -docs can print documentation in.
+var docsFormats = []string{"markdown", "man"}
+
+func isDocsFormat(format string) bool {
+	for _, f := range docsFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// docsSection is a group of targets that are documented together: the targets
+// of the magefiles, of a namespace or of an imported package.
+type docsSection struct {
+	prefix     string // what the names of the targets start with, e.g. "docs" for docs:serve
+	importPath string // the package the targets are imported from, if any
+	funcs      []*parse.Function
+}
+
+// title returns the heading of the section. Words are passed through label,
+// and names and import paths through code, to format them.
+func (s docsSection) title(label, code func(string) string) string {
+	var t string
+	if s.prefix == "" {
+		t = label("Targets")
+	} else {
+		t = label("Namespace ") + code(s.prefix)
+	}
+	if s.importPath != "" {
+		t += label(" from ") + code(s.importPath)
+	}
+	return t
+}
+
+// docsSections returns the targets that aren't hidden, grouped by namespace
+// and import, with the targets of the magefiles first.
+func docsSections(info *parse.PkgInfo) []docsSection {
+	var sections []docsSection
+	index := map[[2]string]int{}
+	add := func(f *parse.Function) {
+		if f.Hidden {
+			return
+		}
+		var prefix []string
+		for _, p := range []string{f.PkgAlias, f.Receiver} {
+			if p != "" {
+				prefix = append(prefix, p)
+			}
+		}
+		key := [2]string{f.ImportPath, lowerFirst(strings.Join(prefix, ":"))}
+		i, ok := index[key]
+		if !ok {
+			i = len(sections)
+			index[key] = i
+			sections = append(sections, docsSection{prefix: key[1], importPath: key[0]})
+		}
+		sections[i].funcs = append(sections[i].funcs, f)
+	}
+	for _, f := range info.Funcs {
+		add(f)
+	}
+	for _, imp := range info.Imports {
+		for _, f := range imp.Info.Funcs {
+			add(f)
+		}
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		if sections[i].importPath != sections[j].importPath {
+			return sections[i].importPath < sections[j].importPath
+		}
+		return sections[i].prefix < sections[j].prefix
+	})
+	for _, s := range sections {
+		sort.SliceStable(s.funcs, func(i, j int) bool {
+			return lowerFirst(s.funcs[i].TargetName()) < lowerFirst(s.funcs[j].TargetName())
+		})
+	}
+	return sections
+}
+
+// aliasesOf returns the sorted aliases of the target.
+func aliasesOf(info *parse.PkgInfo, f *parse.Function) []string {
+	var aliases []string
+	for alias, af := range info.Aliases {
+		if af.Name == f.Name && af.Receiver == f.Receiver && af.PkgAlias == f.PkgAlias {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// writeDocs writes documentation for the targets of the parsed magefiles in
+// the given format.
+func writeDocs(w io.Writer, format string, info *parse.PkgInfo, binaryName string) error {
+	var doc string
+	switch format {
+	case "markdown":
+		doc = markdownDocs(info, binaryName)
+	case "man":
+		doc = manDocs(info, binaryName)
+	default:
+		return fmt.Errorf("unknown docs format %q", format)
+	}
+	_, err := io.WriteString(w, doc)
+	return err
+}
+
+// markdownDocs returns the documentation of the targets as Markdown, with a
+// section for each group of targets and a subsection for each target.
+func markdownDocs(info *parse.PkgInfo, binaryName string) string {
+	var buf strings.Builder
+	code := func(s string) string { return "`" + s + "`" }
+	_, _ = fmt.Fprintf(&buf, "# %s\n\n", binaryName)
+	if info.Description != "" {
+		_, _ = fmt.Fprintf(&buf, "%s\n\n", info.Description)
+	}
+	if info.DefaultFunc != nil && !info.DefaultFunc.Hidden {
+		_, _ = fmt.Fprintf(&buf, "The default target is %s.\n\n", code(lowerFirst(info.DefaultFunc.TargetName())))
+	}
+	for _, s := range docsSections(info) {
+		_, _ = fmt.Fprintf(&buf, "## %s\n\n", s.title(func(s string) string { return s }, code))
+		for _, f := range s.funcs {
+			_, _ = fmt.Fprintf(&buf, "### %s\n\n", lowerFirst(f.TargetName()))
+			if f.Comment != "" {
+				_, _ = fmt.Fprintf(&buf, "%s\n\n", f.Comment)
+			}
+			if f.Deprecated != "" {
+				_, _ = fmt.Fprintf(&buf, "**Deprecated:** %s\n\n", f.Deprecated)
+			}
+			_, _ = fmt.Fprintf(&buf, "```plain\n%s\n```\n\n", usageLine(binaryName, f))
+			if args := f.RequiredArgs(); len(args) > 0 {
+				_, _ = fmt.Fprint(&buf, "Arguments:\n\n")
+				for _, a := range args {
+					writeMarkdownItem(&buf, fmt.Sprintf("<%s> %s", a.Name, a.ValueLabel()), a.Comment)
+				}
+				_, _ = fmt.Fprintln(&buf)
+			}
+			if opts := f.OptionalArgs(); len(opts) > 0 {
+				_, _ = fmt.Fprint(&buf, "Flags:\n\n")
+				for _, a := range opts {
+					writeMarkdownItem(&buf, a.FlagLabel(), a.FlagComment())
+				}
+				_, _ = fmt.Fprintln(&buf)
+			}
+			if aliases := aliasesOf(info, f); len(aliases) > 0 {
+				for i := range aliases {
+					aliases[i] = code(aliases[i])
+				}
+				_, _ = fmt.Fprintf(&buf, "Aliases: %s\n\n", strings.Join(aliases, ", "))
+			}
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeMarkdownItem(w io.Writer, label, comment string) {
+	if comment == "" {
+		_, _ = fmt.Fprintf(w, "- `%s`\n", label)
+	} else {
+		_, _ = fmt.Fprintf(w, "- `%s`: %s\n", label, comment)
+	}
+}
+
+// manDocs returns the documentation of the targets as a roff man page, with a
+// section for each group of targets and a subsection for each target.
+func manDocs(info *parse.PkgInfo, binaryName string) string {
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, ".TH %s 1\n", roffText(strings.ToUpper(binaryName)))
+	_, _ = fmt.Fprintf(&buf, ".SH NAME\n%s \\- run the targets of the magefiles\n", roffText(binaryName))
+	_, _ = fmt.Fprintf(&buf, ".SH SYNOPSIS\n.B %s\n[\\fItarget\\fR [\\fIarguments\\fR]]...\n", roffText(binaryName))
+	showDefault := info.DefaultFunc != nil && !info.DefaultFunc.Hidden
+	if info.Description != "" || showDefault {
+		_, _ = fmt.Fprint(&buf, ".SH DESCRIPTION\n")
+		if info.Description != "" {
+			_, _ = fmt.Fprintf(&buf, "%s\n", roffText(info.Description))
+		}
+		if showDefault {
+			_, _ = fmt.Fprintf(&buf, ".PP\nThe default target is \\fB%s\\fR.\n", roffText(lowerFirst(info.DefaultFunc.TargetName())))
+		}
+	}
+	for _, s := range docsSections(info) {
+		_, _ = fmt.Fprintf(&buf, ".SH \"%s\"\n", roffText(s.title(strings.ToUpper, func(s string) string { return s })))
+		for _, f := range s.funcs {
+			_, _ = fmt.Fprintf(&buf, ".SS %s\n", roffText(lowerFirst(f.TargetName())))
+			if f.Comment != "" {
+				_, _ = fmt.Fprintf(&buf, "%s\n", roffText(f.Comment))
+			}
+			if f.Deprecated != "" {
+				_, _ = fmt.Fprintf(&buf, ".PP\nDeprecated: %s\n", roffText(f.Deprecated))
+			}
+			_, _ = fmt.Fprintf(&buf, ".PP\n.RS\n.nf\n%s\n.fi\n.RE\n", roffFlags(usageLine(binaryName, f)))
+			if args := f.RequiredArgs(); len(args) > 0 {
+				_, _ = fmt.Fprint(&buf, ".PP\nArguments:\n")
+				for _, a := range args {
+					writeManItem(&buf, fmt.Sprintf("<%s> %s", a.Name, a.ValueLabel()), a.Comment)
+				}
+			}
+			if opts := f.OptionalArgs(); len(opts) > 0 {
+				_, _ = fmt.Fprint(&buf, ".PP\nFlags:\n")
+				for _, a := range opts {
+					writeManItem(&buf, a.FlagLabel(), a.FlagComment())
+				}
+			}
+			if aliases := aliasesOf(info, f); len(aliases) > 0 {
+				_, _ = fmt.Fprintf(&buf, ".PP\nAliases: %s\n", roffText(strings.Join(aliases, ", ")))
+			}
+		}
+	}
+	return buf.String()
+}
+
+func writeManItem(w io.Writer, label, comment string) {
+	_, _ = fmt.Fprintf(w, ".TP\n.B %s\n", roffFlags(label))
+	if comment != "" {
+		_, _ = fmt.Fprintf(w, "%s\n", roffText(comment))
+	}
+}
+
+// roffText escapes text for roff, so backslashes and lines starting with a
+// control character are printed as they are. Blank lines start new paragraphs.
+func roffText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case strings.TrimSpace(l) == "":
+			lines[i] = ".PP"
+		case strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'"):
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffFlags escapes a usage line or flag for roff, where hyphens are printed
+// as minus signs so they can be copied.
+func roffFlags(s string) string {
+	return strings.ReplaceAll(roffText(s), "-", `\-`)
+}
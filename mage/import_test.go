@@ -2,6 +2,8 @@ package mage
 
 import (
 	"bytes"
+	"fmt"
+	"path/filepath"
 	"testing"
 )
 
@@ -375,11 +377,15 @@ func TestMageImportsSameNamespaceDupTargets(t *testing.T) {
 	if code != 1 {
 		t.Fatalf("expected to exit with code 1, but got %v, stderr:\n%s", code, stderr)
 	}
+	dir, err := filepath.Abs(inv.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
 	actual := stderr.String()
-	expected := `
-Error parsing magefiles: "samenamespace:build" target has multiple definitions: github.com/magefile/mage/mage/testdata/mageimport/samenamespace/duptargets/package1.Build, github.com/magefile/mage/mage/testdata/mageimport/samenamespace/duptargets/package2.Build
+	expected := fmt.Sprintf(`
+Error parsing magefiles: "samenamespace:build" target has multiple definitions: github.com/magefile/mage/mage/testdata/mageimport/samenamespace/duptargets/package1.Build (%s:5:6), github.com/magefile/mage/mage/testdata/mageimport/samenamespace/duptargets/package2.Build (%s:5:6)
 
-`[1:]
+`[1:], filepath.Join(dir, "package1", "package1.go"), filepath.Join(dir, "package2", "package2.go"))
 	if actual != expected {
 		t.Logf("expected: %q", expected)
 		t.Logf("  actual: %q", actual)
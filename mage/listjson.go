@@ -0,0 +1,163 @@
+package mage
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/magefile/mage/parse"
+)
+
+// jsonListing is what mage -l -json prints: the targets of the magefiles in
+// the current directory, as parsed without compiling them.
+type jsonListing struct {
+	Description string       `json:"description,omitempty"`
+	Default     string       `json:"default,omitempty"`
+	Targets     []jsonTarget `json:"targets"`
+}
+
+// jsonTarget describes a target for editors and other tools.
+type jsonTarget struct {
+	Name       string    `json:"name"`                 // the name as listed, e.g. "docs:serve"
+	Function   string    `json:"function"`             // the name of the Go function
+	Namespace  string    `json:"namespace,omitempty"`  // the namespace type, if any
+	Package    string    `json:"package,omitempty"`    // the alias of the imported package, if any
+	ImportPath string    `json:"importPath,omitempty"` // the import path, for targets from mage:import
+	Synopsis   string    `json:"synopsis"`
+	Comment    string    `json:"comment"`
+	Args       []jsonArg `json:"args"`
+	Aliases    []string  `json:"aliases,omitempty"`
+	Default    bool      `json:"default,omitempty"`
+	Hidden     bool      `json:"hidden,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	Deprecated string    `json:"deprecated,omitempty"`
+	File       string    `json:"file"`
+	Line       int       `json:"line"`
+	Column     int       `json:"column"`
+}
+
+// jsonArg describes an argument of a target, with options structs replaced by
+// their fields like on the command line.
+type jsonArg struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Optional bool     `json:"optional"`
+	Comment  string   `json:"comment,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+	Default  string   `json:"default,omitempty"`
+	Env      string   `json:"env,omitempty"`
+}
+
+// jsonCompletion is a candidate printed by mage -autocomplete -json.
+type jsonCompletion struct {
+	Name     string `json:"name"`
+	Synopsis string `json:"synopsis,omitempty"`
+}
+
+// newJSONTarget returns the description of a target for the JSON listing.
+func newJSONTarget(f *parse.Function, info *parse.PkgInfo) jsonTarget {
+	t := jsonTarget{
+		Name:       lowerFirst(f.TargetName()),
+		Function:   f.Name,
+		Namespace:  f.Receiver,
+		Package:    f.PkgAlias,
+		ImportPath: f.ImportPath,
+		Synopsis:   f.Synopsis,
+		Comment:    f.Comment,
+		Args:       []jsonArg{},
+		Hidden:     f.Hidden,
+		Group:      f.Group,
+		Deprecated: f.Deprecated,
+		File:       f.File,
+		Line:       f.Line,
+		Column:     f.Column,
+	}
+	if abs, err := filepath.Abs(f.File); err == nil {
+		t.File = abs
+	}
+	for _, a := range append(f.RequiredArgs(), f.OptionalArgs()...) {
+		t.Args = append(t.Args, jsonArg{
+			Name:     a.Name,
+			Type:     a.Type,
+			Optional: a.Optional,
+			Comment:  a.Comment,
+			Choices:  a.Choices,
+			Default:  a.Default,
+			Env:      a.Env,
+		})
+	}
+	t.Aliases = aliasesOf(info, f)
+	if d := info.DefaultFunc; d != nil {
+		t.Default = d.Name == f.Name && d.Receiver == f.Receiver && d.PkgAlias == f.PkgAlias
+	}
+	return t
+}
+
+// writeJSONList writes the targets of the parsed magefiles as JSON, sorted by
+// name.
+func writeJSONList(w io.Writer, info *parse.PkgInfo) error {
+	listing := jsonListing{
+		Description: info.Description,
+		Targets:     []jsonTarget{},
+	}
+	if info.DefaultFunc != nil {
+		listing.Default = lowerFirst(info.DefaultFunc.TargetName())
+	}
+	for _, f := range info.Funcs {
+		listing.Targets = append(listing.Targets, newJSONTarget(f, info))
+	}
+	for _, imp := range info.Imports {
+		for _, f := range imp.Info.Funcs {
+			listing.Targets = append(listing.Targets, newJSONTarget(f, info))
+		}
+	}
+	sort.Slice(listing.Targets, func(i, j int) bool {
+		return listing.Targets[i].Name < listing.Targets[j].Name
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(listing)
+}
+
+// writeJSONCompletions writes the candidates for shell completion as JSON:
+// the choices for the next argument of a target, or the names of the targets
+// and aliases with their synopses.
+func writeJSONCompletions(w io.Writer, info *parse.PkgInfo, args []string) error {
+	completions := []jsonCompletion{}
+	if choices, ok := argChoices(info, args); ok {
+		for _, c := range choices {
+			completions = append(completions, jsonCompletion{Name: c})
+		}
+	} else {
+		synopses := map[string]string{}
+		add := func(f *parse.Function) {
+			if !f.Hidden {
+				synopses[strings.ToLower(f.TargetName())] = f.Synopsis
+			}
+		}
+		for _, f := range info.Funcs {
+			add(f)
+		}
+		for _, imp := range info.Imports {
+			for _, f := range imp.Info.Funcs {
+				add(f)
+			}
+		}
+		for alias, f := range info.Aliases {
+			synopses[strings.ToLower(alias)] = f.Synopsis
+		}
+		names := make([]string, 0, len(synopses))
+		for name := range synopses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			completions = append(completions, jsonCompletion{Name: name, Synopsis: synopses[name]})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(completions)
+}
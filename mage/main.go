@@ -53,18 +53,36 @@ func lowerFirstWord(s string) string {
 	return strings.ToLower(s)
 }
 
+// lowerFirst lowers the first word of each part of a target name, which is
+// how targets are listed.
+func lowerFirst(s string) string {
+	parts := strings.Split(s, ":")
+	for i, t := range parts {
+		parts[i] = lowerFirstWord(t)
+	}
+	return strings.Join(parts, ":")
+}
+
 var mainfileTemplate = template.Must(template.New("").Funcs(map[string]interface{}{
-	"lower": strings.ToLower,
-	"lowerFirst": func(s string) string {
-		parts := strings.Split(s, ":")
-		for i, t := range parts {
-			parts[i] = lowerFirstWord(t)
-		}
-		return strings.Join(parts, ":")
-	},
+	"lower":      strings.ToLower,
+	"lowerFirst": lowerFirst,
+	"funcName":   funcName,
 }).Parse(mageMainfileTplString))
 var initOutput = template.Must(template.New("").Parse(mageTpl))
 
+// funcName returns the name the go runtime reports for the target function,
+// which is how mg identifies the callers of its dependencies.
+func funcName(f parse.Function) string {
+	pkg := "main"
+	if f.ImportPath != "" {
+		pkg = f.ImportPath
+	}
+	if f.Receiver != "" {
+		return pkg + "." + f.Receiver + "." + f.Name
+	}
+	return pkg + "." + f.Name
+}
+
 const (
 	mainfile = "mage_output_file.go"
 	initFile = "magefile.go"
@@ -119,6 +137,15 @@ type Invocation struct {
 	Multiline    bool          // whether to retain line returns in help text for the generated main file
 	Autocomplete bool          // parse magefiles and print target names for shell completion
 	InstallShell string        // shell to install tab completion for (bash, zsh, fish, powershell/pwsh)
+	Graph        string        // file to write the dependency graph of the targets run to, or "-" for stdout
+	Timings      bool          // print a summary of how long targets and dependencies took after running
+	Jobs         int           // maximum number of dependencies to run at the same time, 0 for no limit
+	FailFast     bool          // cancel the remaining dependencies as soon as one fails
+	KeepGoing    bool          // run all the targets given, even if some fail
+	Trace        string        // file or OTLP/HTTP endpoint to export a trace of the targets run to
+	JSON         bool          // print the output of List or Autocomplete as JSON
+	Docs         string        // print documentation for the targets in this format (markdown or man)
+	Watch        bool          // run the targets again whenever the files they depend on change
 }
 
 // MagefilesDirName is the name of the default folder to look for if no directory was specified,
@@ -230,7 +257,16 @@ func Parse(stderr, stdout io.Writer, args []string) (inv Invocation, cmd Command
 	fs.StringVar(&inv.GOOS, "goos", "", "set GOOS for binary produced with -compile")
 	fs.StringVar(&inv.GOARCH, "goarch", "", "set GOARCH for binary produced with -compile")
 	fs.StringVar(&inv.Ldflags, "ldflags", "", "set ldflags for binary produced with -compile")
-	fs.BoolVar(&inv.Autocomplete, "autocomplete", false, "print target names for shell completion, without compiling")
+	fs.BoolVar(&inv.Autocomplete, "autocomplete", false, "print target names, or the choices for the next argument of the target in the given args, for shell completion, without compiling")
+	fs.StringVar(&inv.Graph, "graph", mg.GraphFile(), "write the graph of the dependencies run by the targets to the given file once they're done (.dot or .json, - for stdout)")
+	fs.BoolVar(&inv.Timings, "timings", mg.Timings(), "print how long each target and dependency took after running")
+	fs.IntVar(&inv.Jobs, "j", mg.Jobs(), "run at most this many dependencies at the same time (default: no limit)")
+	fs.BoolVar(&inv.FailFast, "failfast", mg.FailFast(), "cancel the remaining dependencies as soon as one fails")
+	fs.BoolVar(&inv.KeepGoing, "k", mg.KeepGoing(), "keep going and run all targets even if some fail")
+	fs.StringVar(&inv.Trace, "trace", mg.Trace(), "export a trace of the targets run as OTLP/JSON to the given file or http(s) endpoint")
+	fs.BoolVar(&inv.JSON, "json", false, "print the output of -l or -autocomplete as JSON")
+	fs.StringVar(&inv.Docs, "docs", "", "print documentation for the targets in the given format (markdown or man)")
+	fs.BoolVar(&inv.Watch, "watch", false, "run the targets again whenever the magefiles or the files they depend on change")
 
 	// commands below
 
@@ -254,10 +290,13 @@ Mage is a make-like command runner.  See https://magefile.org for full docs.
 
 Commands:
   -autocomplete
-            print target names for shell completion, without compiling
+            print target names (or the choices for the next argument of the
+            target in the given args) for shell completion, without compiling
   -clean    clean out old generated binaries from CACHE_DIR
   -compile <string>
             output a static binary to the given path
+  -docs <string>
+            print documentation for the targets as markdown or man
   -h        show this help
   -init     create a starting template if no mage files exist
   -install <string>
@@ -270,19 +309,34 @@ Options:
               directory to read magefiles from (default "." or "magefiles" if exists)
   -debug      turn on debug messages
   -f          force recreation of compiled magefile
+  -failfast   cancel the remaining dependencies as soon as one fails
   -goarch     sets the GOARCH for the binary created by -compile (default: current arch)
   -gocmd <string>
 		      use the given go binary to compile the output (default: "go")
   -goos       sets the GOOS for the binary created by -compile (default: current OS)
+  -graph <string>
+              run the targets and write the graph of the dependencies they ran
+              to the given file once they're done (JSON if it ends in .json,
+              Graphviz DOT otherwise, or DOT on stdout for -)
   -ldflags    sets the ldflags for the binary created by -compile (default: "")
   -multiline  retain line returns in help docs (default: convert to spaces)
   -h          show description of a target
+  -j <int>
+              run at most this many dependencies at the same time (default: no limit)
+  -json       print the output of -l or -autocomplete as JSON
+  -k          keep going and run all targets even if some fail
   -keep       keep intermediate mage files around after running
   -t <string>
               timeout in duration parsable format (e.g. 5m30s)
+  -timings    print how long each target and dependency took after running
+  -trace <string>
+              export a trace of the targets, dependencies and commands run as
+              OTLP/JSON to the given file or http(s) endpoint
   -v          show verbose output when running mage targets
   -w <string>
               working directory where magefiles will run (default -d value)
+  -watch      run the targets again whenever the magefiles or the files they
+              depend on change, until interrupted
 `[1:])
 	}
 	err = fs.Parse(args)
@@ -317,7 +371,7 @@ Options:
 		numCommands++
 		cmd = Clean
 		if fs.NArg() > 0 {
-			return inv, cmd, errors.New("-h, -init, -clean, -compile, -install, -autocomplete and -version cannot be used simultaneously")
+			return inv, cmd, errors.New("-h, -init, -clean, -compile, -install, -autocomplete, -docs and -version cannot be used simultaneously")
 		}
 	default:
 		// no command flags set
@@ -328,6 +382,9 @@ Options:
 	if inv.Autocomplete {
 		numCommands++
 	}
+	if inv.Docs != "" {
+		numCommands++
+	}
 
 	if inv.Debug {
 		debug.SetOutput(stderr)
@@ -337,7 +394,23 @@ Options:
 
 	if numCommands > 1 {
 		debug.Printf("%d commands defined", numCommands)
-		return inv, cmd, errors.New("-h, -init, -clean, -compile, -install, -autocomplete and -version cannot be used simultaneously")
+		return inv, cmd, errors.New("-h, -init, -clean, -compile, -install, -autocomplete, -docs and -version cannot be used simultaneously")
+	}
+
+	if inv.Jobs < 0 {
+		return inv, cmd, errors.New("-j must not be negative")
+	}
+
+	if inv.JSON && !inv.List && !inv.Autocomplete {
+		return inv, cmd, errors.New("-json only applies to -l and -autocomplete")
+	}
+
+	if inv.Watch && (numCommands > 0 || inv.List) {
+		return inv, cmd, errors.New("-watch can only be used when running targets")
+	}
+
+	if inv.Docs != "" && !isDocsFormat(inv.Docs) {
+		return inv, cmd, fmt.Errorf("unknown -docs format %q, must be one of: %s", inv.Docs, strings.Join(docsFormats, ", "))
 	}
 
 	if cmd != CompileStatic && (inv.GOARCH != "" || inv.GOOS != "") {
@@ -349,7 +422,7 @@ Options:
 		return inv, cmd, errors.New("-h can only show help for a single target")
 	}
 
-	if len(inv.Args) > 0 && cmd != None {
+	if len(inv.Args) > 0 && (cmd != None || inv.Docs != "") {
 		return inv, cmd, fmt.Errorf("unexpected arguments to command: %q", inv.Args)
 	}
 	inv.HashFast = mg.HashFast()
@@ -364,6 +437,21 @@ var colorsFile string
 // Invoke runs Mage with the given arguments.
 func Invoke(inv Invocation) int {
 	errlog := log.New(inv.Stderr, "", 0)
+	exePath, code, ok := prepare(&inv, errlog)
+	if !ok {
+		return code
+	}
+	if inv.Watch {
+		return watch(inv, exePath, errlog)
+	}
+	return RunCompiled(inv, exePath, errlog)
+}
+
+// prepare compiles the magefiles, unless the binary compiled from them before
+// can be used, and returns the path to the binary to run. If mage is done
+// instead, because it printed the output of a command, compiled the binary with
+// -compile or failed, ok is false and code is the exit code.
+func prepare(inv *Invocation, errlog *log.Logger) (exePath string, code int, ok bool) {
 	if inv.GoCmd == "" {
 		inv.GoCmd = "go"
 	}
@@ -395,20 +483,20 @@ func Invoke(inv Invocation) int {
 	files, err := Magefiles(inv.Dir, inv.GOOS, inv.GOARCH, inv.UsesMagefiles())
 	if err != nil {
 		errlog.Println("Error determining list of magefiles:", err)
-		return 1
+		return "", 1, false
 	}
 
 	if len(files) == 0 {
 		errlog.Println("No .go files marked with the mage build tag in this directory.")
-		return 1
+		return "", 1, false
 	}
 	debug.Printf("found magefiles: %s", strings.Join(files, ", "))
-	exePath := inv.CompileOut
+	exePath = inv.CompileOut
 	if inv.CompileOut == "" {
 		exePath, err = ExeName(inv.GoCmd, inv.CacheDir, files)
 		if err != nil {
 			errlog.Println("Error getting exe name:", err)
-			return 1
+			return "", 1, false
 		}
 	}
 	debug.Println("output exe is ", exePath)
@@ -420,7 +508,7 @@ func Invoke(inv Invocation) int {
 		gocache, gocacheErr := internal.OutputDebug(inv.GoCmd, "env", "GOCACHE")
 		if gocacheErr != nil {
 			errlog.Printf("failed to run %s env GOCACHE: %s", inv.GoCmd, gocacheErr)
-			return 1
+			return "", 1, false
 		}
 
 		// if GOCACHE exists, always rebuild, so we catch transitive
@@ -431,13 +519,16 @@ func Invoke(inv Invocation) int {
 		}
 	}
 
-	if !useCache {
+	// documentation, completions and JSON listings are generated from the
+	// magefiles, not the compiled binary, which would run the words being
+	// completed as targets.
+	if !useCache && inv.Docs == "" && !inv.Autocomplete && !(inv.List && inv.JSON) {
 		_, err = os.Stat(exePath)
 		switch {
 		case err == nil:
 			if !inv.Force {
 				debug.Println("Running existing exe")
-				return RunCompiled(inv, exePath, errlog)
+				return exePath, 0, true
 			}
 			debug.Println("ignoring existing executable")
 		case os.IsNotExist(err):
@@ -460,11 +551,24 @@ func Invoke(inv Invocation) int {
 	info, err := parse.PrimaryPackage(inv.GoCmd, inv.Dir, fnames, inv.Multiline)
 	if err != nil {
 		errlog.Println("Error parsing magefiles:", err)
-		return 1
+		return "", 1, false
 	}
 
+	if inv.Autocomplete && inv.JSON {
+		if err := writeJSONCompletions(inv.Stdout, info, inv.Args); err != nil {
+			errlog.Println("Error:", err)
+			return "", 1, false
+		}
+		return "", 0, false
+	}
 	if inv.Autocomplete {
-		return printAutocompleteTargets(inv.Stdout, info)
+		if choices, ok := argChoices(info, inv.Args); ok {
+			for _, c := range choices {
+				_, _ = fmt.Fprintln(inv.Stdout, c)
+			}
+			return "", 0, false
+		}
+		return "", printAutocompleteTargets(inv.Stdout, info), false
 	}
 
 	// reproducible output for deterministic builds
@@ -482,21 +586,37 @@ func Invoke(inv Invocation) int {
 		Aliases:     info.Aliases,
 		Imports:     info.Imports,
 		BinaryName:  binaryName,
+		Hooks:       info.HasHooks,
 	}
 
 	if info.DefaultFunc != nil {
 		data.DefaultFunc = *info.DefaultFunc
 	}
 
+	if inv.List && inv.JSON {
+		if err := writeJSONList(inv.Stdout, info); err != nil {
+			errlog.Println("Error:", err)
+			return "", 1, false
+		}
+		return "", 0, false
+	}
 	if inv.List {
 		_, _ = fmt.Fprint(inv.Stdout, mageListOutput(data, info))
-		return 0
+		return "", 0, false
+	}
+
+	if inv.Docs != "" {
+		if err := writeDocs(inv.Stdout, inv.Docs, info, binaryName); err != nil {
+			errlog.Println("Error:", err)
+			return "", 1, false
+		}
+		return "", 0, false
 	}
 
 	if inv.Help {
 		if len(inv.Args) < 1 {
 			_, _ = fmt.Fprintln(inv.Stderr, "no target specified")
-			return 2
+			return "", 2, false
 		}
 		output, code := mageHelpOutput(data, inv.Args[0])
 		if code != 0 {
@@ -504,7 +624,7 @@ func Invoke(inv Invocation) int {
 		} else {
 			_, _ = fmt.Fprint(inv.Stdout, output)
 		}
-		return code
+		return "", code, false
 	}
 
 	// ensure we use the same color output code in the generated mainfile as we do in mage's own output.
@@ -518,7 +638,7 @@ func Invoke(inv Invocation) int {
 	err = GenerateMainfile(data, main)
 	if err != nil {
 		errlog.Println("Error:", err)
-		return 1
+		return "", 1, false
 	}
 	if !inv.Keep {
 		defer func() { _ = os.RemoveAll(main) }()
@@ -526,7 +646,7 @@ func Invoke(inv Invocation) int {
 	files = append(files, main)
 	if err := Compile(inv.GOOS, inv.GOARCH, inv.Ldflags, inv.Dir, inv.GoCmd, exePath, files, inv.Debug, inv.Stderr, inv.Stdout); err != nil {
 		errlog.Println("Error:", err)
-		return 1
+		return "", 1, false
 	}
 	if !inv.Keep {
 		// move aside this file before we run the compiled version, in case the
@@ -538,23 +658,15 @@ func Invoke(inv Invocation) int {
 	}
 
 	if inv.CompileOut != "" {
-		return 0
+		return "", 0, false
 	}
 
-	return RunCompiled(inv, exePath, errlog)
+	return exePath, 0, true
 }
 
 func mageListOutput(data mainfileTemplateData, info *parse.PkgInfo) string {
 	list := strings.Builder{}
 
-	lowerFirst := func(s string) string {
-		parts := strings.Split(s, ":")
-		for i, t := range parts {
-			parts[i] = lowerFirstWord(t)
-		}
-		return strings.Join(parts, ":")
-	}
-
 	var defaultFunc parse.Function
 	if info.DefaultFunc != nil {
 		defaultFunc = *info.DefaultFunc
@@ -565,20 +677,24 @@ func mageListOutput(data mainfileTemplateData, info *parse.PkgInfo) string {
 	}
 
 	targets := map[string]string{}
-	for _, f := range data.Funcs {
+	groups := map[string]string{}
+	add := func(f *parse.Function) {
+		if f.Hidden {
+			return
+		}
 		name := lowerFirst(f.TargetName())
 		if f.Name == defaultFunc.Name && f.Receiver == defaultFunc.Receiver {
 			name += "*"
 		}
-		targets[name] = f.Synopsis
+		targets[name] = f.ListSynopsis()
+		groups[name] = f.Group
+	}
+	for _, f := range data.Funcs {
+		add(f)
 	}
 	for _, imp := range data.Imports {
 		for _, f := range imp.Info.Funcs {
-			name := lowerFirst(f.TargetName())
-			if f.Name == defaultFunc.Name && f.Receiver == defaultFunc.Receiver {
-				name += "*"
-			}
-			targets[name] = f.Synopsis
+			add(f)
 		}
 	}
 
@@ -588,13 +704,38 @@ func mageListOutput(data mainfileTemplateData, info *parse.PkgInfo) string {
 	}
 	sort.Strings(keys)
 
-	_, _ = fmt.Fprintln(&list, "Targets:")
-	w := tabwriter.NewWriter(&list, 0, 4, 4, ' ', 0)
+	// targets without a group are listed first, then each group under its
+	// own heading.
+	byGroup := map[string][]string{}
+	var groupNames []string
 	for _, name := range keys {
-		_, _ = fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+		g := groups[name]
+		if _, ok := byGroup[g]; !ok && g != "" {
+			groupNames = append(groupNames, g)
+		}
+		byGroup[g] = append(byGroup[g], name)
+	}
+	sort.Strings(groupNames)
+
+	w := tabwriter.NewWriter(&list, 0, 4, 4, ' ', 0)
+	if len(byGroup[""]) > 0 || len(groupNames) == 0 {
+		_, _ = fmt.Fprintln(w, "Targets:")
+		for _, name := range byGroup[""] {
+			_, _ = fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+		}
+	}
+	for i, g := range groupNames {
+		if i > 0 || len(byGroup[""]) > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "%s:\n", g)
+		for _, name := range byGroup[g] {
+			_, _ = fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+		}
 	}
 	_ = w.Flush()
-	if defaultFunc.Name != "" {
+	// a hidden default target isn't listed, so neither is the note about it.
+	if defaultFunc.Name != "" && !defaultFunc.Hidden {
 		_, _ = fmt.Fprintln(&list, "\n* default target")
 	}
 	return list.String()
@@ -631,21 +772,15 @@ func mageHelpOutput(data mainfileTemplateData, target string) (output string, co
 		_, _ = fmt.Fprintln(&buf, fn.Comment)
 		_, _ = fmt.Fprintln(&buf)
 	}
-
-	// Build usage line matching template format.
-	_, _ = fmt.Fprintf(&buf, "Usage:\n\n\t%s %s", data.BinaryName, strings.ToLower(fn.TargetName()))
-	for _, a := range fn.RequiredArgs() {
-		_, _ = fmt.Fprintf(&buf, " <%s>", a.Name)
+	if fn.Deprecated != "" {
+		_, _ = fmt.Fprintf(&buf, "Deprecated: %s\n\n", fn.Deprecated)
 	}
-	if fn.MultipleOptionalArgs() {
-		_, _ = fmt.Fprint(&buf, " [<flags>]")
-	} else {
-		for _, a := range fn.OptionalArgs() {
-			_, _ = fmt.Fprintf(&buf, " [-%s=<%s>]", a.Name, a.Type)
-		}
-	}
-	_, _ = fmt.Fprint(&buf, "\n\n")
 
+	_, _ = fmt.Fprintf(&buf, "Usage:\n\n\t%s\n\n", usageLine(data.BinaryName, fn))
+
+	if fn.ShowArgDocs() {
+		_, _ = fmt.Fprint(&buf, fn.ArgDocsString())
+	}
 	if fn.ShowFlagDocs() {
 		_, _ = fmt.Fprint(&buf, fn.FlagDocsString())
 	}
@@ -665,16 +800,78 @@ func mageHelpOutput(data mainfileTemplateData, target string) (output string, co
 	return buf.String(), 0
 }
 
+// usageLine returns how to run the target, matching the usage line of the help
+// of the compiled binary.
+func usageLine(binaryName string, fn *parse.Function) string {
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "%s %s", binaryName, strings.ToLower(fn.TargetName()))
+	for _, a := range fn.RequiredArgs() {
+		_, _ = fmt.Fprintf(&buf, " <%s>", a.Name)
+	}
+	if fn.MultipleOptionalArgs() {
+		_, _ = fmt.Fprint(&buf, " [<flags>]")
+	} else {
+		for _, a := range fn.OptionalArgs() {
+			_, _ = fmt.Fprintf(&buf, " [%s]", a.FlagLabel())
+		}
+	}
+	return buf.String()
+}
+
+// argChoices returns the values to complete after the given words of a mage
+// command line, when they end in the middle of the required arguments of a
+// target. Arguments restricted with mage:enum complete to their choices, and
+// other arguments to nothing. Otherwise ok is false, and the next word is a
+// target name.
+func argChoices(info *parse.PkgInfo, words []string) (choices []string, ok bool) {
+	targets := map[string]*parse.Function{}
+	for _, f := range info.Funcs {
+		targets[strings.ToLower(f.TargetName())] = f
+	}
+	for _, imp := range info.Imports {
+		for _, f := range imp.Info.Funcs {
+			targets[strings.ToLower(f.TargetName())] = f
+		}
+	}
+	for alias, f := range info.Aliases {
+		targets[strings.ToLower(alias)] = f
+	}
+
+	var args []parse.Arg
+	for _, w := range words {
+		if strings.HasPrefix(w, "-") {
+			// mage flags and target options
+			continue
+		}
+		if len(args) > 0 {
+			args = args[1:]
+			continue
+		}
+		if f, ok := targets[strings.ToLower(w)]; ok {
+			args = f.RequiredArgs()
+		}
+	}
+	if len(args) == 0 {
+		return nil, false
+	}
+	return args[0].Choices, true
+}
+
 // printAutocompleteTargets outputs target names one per line for shell completion.
+// Hidden targets are left out, like in listings.
 func printAutocompleteTargets(stdout io.Writer, info *parse.PkgInfo) int {
 	names := map[string]struct{}{}
 
 	for _, f := range info.Funcs {
-		names[strings.ToLower(f.TargetName())] = struct{}{}
+		if !f.Hidden {
+			names[strings.ToLower(f.TargetName())] = struct{}{}
+		}
 	}
 	for _, imp := range info.Imports {
 		for _, f := range imp.Info.Funcs {
-			names[strings.ToLower(f.TargetName())] = struct{}{}
+			if !f.Hidden {
+				names[strings.ToLower(f.TargetName())] = struct{}{}
+			}
 		}
 	}
 	for alias := range info.Aliases {
@@ -701,6 +898,7 @@ type mainfileTemplateData struct {
 	Imports       []*parse.Import
 	BinaryName    string
 	PrintNameFunc string
+	Hooks         bool // the magefile declares a Hooks variable to run around targets
 }
 
 // listGoFiles returns a list of all .go files in a given directory,
@@ -932,6 +1130,12 @@ func generateInit(dir string) error {
 
 // RunCompiled runs an already-compiled mage command with the given args.
 func RunCompiled(inv Invocation, exePath string, errlog *log.Logger) int {
+	return runCompiled(context.Background(), inv, exePath, errlog)
+}
+
+// runCompiled runs the compiled mage command like RunCompiled, with the given
+// extra environment variables, and interrupts it when ctx is done.
+func runCompiled(ctx context.Context, inv Invocation, exePath string, errlog *log.Logger, env ...string) int {
 	debug.Println("running binary", exePath)
 	c := exec.CommandContext(context.Background(), exePath, inv.Args...)
 	c.Stderr = inv.Stderr
@@ -943,7 +1147,7 @@ func RunCompiled(inv Invocation, exePath string, errlog *log.Logger) int {
 	}
 	// intentionally pass through unaltered os.Environ here.. your magefile has
 	// to deal with it.
-	c.Env = os.Environ()
+	c.Env = append(os.Environ(), env...)
 	if inv.Verbose {
 		c.Env = append(c.Env, "MAGEFILE_VERBOSE=1")
 	}
@@ -962,18 +1166,143 @@ func RunCompiled(inv Invocation, exePath string, errlog *log.Logger) int {
 	if inv.Timeout > 0 {
 		c.Env = append(c.Env, fmt.Sprintf("MAGEFILE_TIMEOUT=%s", inv.Timeout.String()))
 	}
+	if inv.Jobs > 0 {
+		c.Env = append(c.Env, fmt.Sprintf("%s=%d", mg.JobsEnv, inv.Jobs))
+	}
+	if inv.FailFast {
+		c.Env = append(c.Env, mg.FailFastEnv+"=1")
+	}
+	if inv.KeepGoing {
+		c.Env = append(c.Env, mg.KeepGoingEnv+"=1")
+	}
+	var graphFile string
+	if inv.Graph != "" {
+		f, err := os.CreateTemp("", "mage-graph")
+		if err != nil {
+			errlog.Printf("can't create file for dependency graph: %v", err)
+			return 1
+		}
+		graphFile = f.Name()
+		_ = f.Close()
+		defer func() { _ = os.Remove(graphFile) }()
+		c.Env = append(c.Env, fmt.Sprintf("%s=%s", mg.GraphFileEnv, graphFile))
+	}
+	var timingsFile string
+	if inv.Timings || inv.Trace != "" {
+		f, err := os.CreateTemp("", "mage-timings")
+		if err != nil {
+			errlog.Printf("can't create file for timings: %v", err)
+			return 1
+		}
+		timingsFile = f.Name()
+		_ = f.Close()
+		defer func() { _ = os.Remove(timingsFile) }()
+		c.Env = append(c.Env, fmt.Sprintf("%s=%s", mg.TimingsFileEnv, timingsFile))
+	}
 	debug.Print("running magefile with mage vars:\n", strings.Join(filter(c.Env, "MAGEFILE"), "\n"))
 	// catch SIGINT to allow magefile to handle them
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT)
 	defer signal.Stop(sigCh)
-	err := c.Run()
+	err := runCmd(ctx, c)
 	if !sh.CmdRan(err) {
 		errlog.Printf("failed to run compiled magefile: %v", err)
 	}
+	if timingsFile != "" {
+		reportTimings(inv, timingsFile, errlog)
+	}
+	if graphFile != "" {
+		writeGraph(inv, graphFile, errlog)
+	}
 	return sh.ExitStatus(err)
 }
 
+// killTimeout is how long an interrupted compiled magefile has to exit before
+// it is killed, which is a little longer than it gives its targets to clean up.
+const killTimeout = 6 * time.Second
+
+// runCmd runs the command like c.Run, but when ctx is done, it interrupts the
+// command, so the targets it runs are cancelled, and kills it if it doesn't
+// exit within killTimeout.
+func runCmd(ctx context.Context, c *exec.Cmd) error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	// interrupts can't be sent on windows, so the command is killed right away
+	// there.
+	if err := c.Process.Signal(os.Interrupt); err != nil {
+		_ = c.Process.Kill()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(killTimeout):
+		_ = c.Process.Kill()
+		return <-done
+	}
+}
+
+// reportTimings prints the summary of the timings the compiled magefile
+// recorded in the given file, and exports them as a trace, as requested.
+func reportTimings(inv Invocation, path string, errlog *log.Logger) {
+	f, err := os.Open(path)
+	if err != nil {
+		errlog.Printf("can't read timings: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	timings, err := readTimings(f)
+	if err != nil {
+		errlog.Printf("can't read timings: %v", err)
+		return
+	}
+	if len(timings) == 0 {
+		return
+	}
+	if inv.Timings {
+		_, _ = fmt.Fprint(inv.Stderr, "\n"+timingsReport(timings))
+	}
+	if inv.Trace != "" {
+		if err := exportTrace(inv.Trace, timings); err != nil {
+			errlog.Printf("can't export trace: %v", err)
+		}
+	}
+}
+
+// writeGraph writes the dependency graph the compiled magefile recorded in the
+// given file to the file the user asked for, or to stdout in the DOT language
+// for "-". The graph is only written once the compiled magefile exited, so it
+// has the dependencies of all the targets that were run, and it's written even
+// if one of them failed.
+func writeGraph(inv Invocation, path string, errlog *log.Logger) {
+	f, err := os.Open(path)
+	if err != nil {
+		errlog.Printf("can't read dependency graph: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	g, err := mg.ReadGraph(f)
+	if err != nil {
+		errlog.Printf("can't read dependency graph: %v", err)
+		return
+	}
+	if inv.Graph == "-" {
+		err = g.WriteDOT(inv.Stdout)
+	} else {
+		err = g.WriteFile(inv.Graph)
+	}
+	if err != nil {
+		errlog.Println("Error:", err)
+	}
+}
+
 func filter(list []string, prefix string) []string {
 	var out []string
 	for _, s := range list {
@@ -7,6 +7,7 @@ import (
 	"debug/macho"
 	"debug/pe"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -1394,6 +1395,20 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseJobs(t *testing.T) {
+	inv, _, err := Parse(io.Discard, io.Discard, []string{"-j", "4", "build"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if inv.Jobs != 4 {
+		t.Errorf("expected jobs to be 4 but was %d", inv.Jobs)
+	}
+	_, _, err = Parse(io.Discard, io.Discard, []string{"-j", "-1", "build"})
+	if err == nil || err.Error() != "-j must not be negative" {
+		t.Errorf("expected error for negative -j, but got %v", err)
+	}
+}
+
 func TestSetDir(t *testing.T) {
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
@@ -1459,6 +1474,349 @@ func TestTimeout(t *testing.T) {
 	}
 }
 
+func TestGraph(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "deps.dot")
+	inv := Invocation{
+		Dir:    "testdata/graph",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"build"},
+		Graph:  path,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph mage {
+	"Build";
+	"Generate";
+	"Compile(\"server\")";
+	"Build" -> "Generate";
+	"Build" -> "Compile(\"server\")";
+	"Compile(\"server\")" -> "Generate";
+}
+`
+	if actual := string(b); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestGraphNoDeps(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "deps.json")
+	inv := Invocation{
+		Dir:    "testdata/graph",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"nodeps"},
+		Graph:  path,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\n  \"nodes\": [],\n  \"edges\": []\n}\n"
+	if actual := string(b); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestGraphStdout(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "testdata/graph",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"fail"},
+		Graph:  "-",
+	}
+	code := Invoke(inv)
+	if code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	expected := `digraph mage {
+	"Fail";
+	"Generate";
+	"Fail" -> "Generate";
+}
+`
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+	if _, err := os.Stat("-"); err == nil {
+		t.Fatal("expected no file named - to be written")
+	}
+}
+
+func TestGraphFailed(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "deps.dot")
+	inv := Invocation{
+		Dir:    "testdata/graph",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"fail"},
+		Graph:  path,
+	}
+	code := Invoke(inv)
+	if code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph mage {
+	"Fail";
+	"Generate";
+	"Fail" -> "Generate";
+}
+`
+	if actual := string(b); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "testdata/jobs",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"parallel"},
+		Jobs:   2,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "jobs=2 most=2\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:      "testdata/failfast",
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Args:     []string{"build"},
+		FailFast: true,
+	}
+	code := Invoke(inv)
+	if code != 3 {
+		t.Fatalf("expected 3, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "slow cancelled\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+	expected = "Error: fail\ncontext canceled\n"
+	if actual := stderr.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:       "testdata/keepgoing",
+		Stdout:    stdout,
+		Stderr:    stderr,
+		Args:      []string{"lint", "test", "vet"},
+		KeepGoing: true,
+	}
+	code := Invoke(inv)
+	// lint and vet failed with different exit codes.
+	if code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "test ran\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+	expected = `Error: lint failed
+Error: vet failed
+
+Summary:
+  lint    failed (exit code 3)
+  test    passed
+  vet     failed (exit code 1)
+`
+	if actual := stderr.String(); actual != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
+
+func TestKeepGoingPassed(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:       "testdata/keepgoing",
+		Stdout:    io.Discard,
+		Stderr:    stderr,
+		Args:      []string{"test"},
+		KeepGoing: true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("expected no summary when all targets passed, but got %q", stderr)
+	}
+
+	stderr.Reset()
+	inv.Verbose = true
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "\nSummary:\n  test    passed\n"
+	if actual := stderr.String(); !strings.HasSuffix(actual, expected) {
+		t.Fatalf("expected the summary with -v, but got %q", actual)
+	}
+}
+
+func TestKeepGoingExitCode(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:       "testdata/keepgoing",
+		Stdout:    io.Discard,
+		Stderr:    stderr,
+		Args:      []string{"lint", "test"},
+		KeepGoing: true,
+	}
+	if code := Invoke(inv); code != 3 {
+		t.Fatalf("expected 3, but got %v, stderr: %q", code, stderr)
+	}
+}
+
+func TestKeepGoingSkipped(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:       "testdata/keepgoing",
+		Stdout:    io.Discard,
+		Stderr:    stderr,
+		Args:      []string{"slow", "test"},
+		KeepGoing: true,
+		Timeout:   100 * time.Millisecond,
+	}
+	if code := Invoke(inv); code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "\nSummary:\n  slow    failed (exit code 1)\n  test    skipped\n"
+	if actual := stderr.String(); !strings.HasSuffix(actual, expected) {
+		t.Fatalf("expected output to end with %q, but got %q", expected, actual)
+	}
+}
+
+func TestNoKeepGoing(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "testdata/keepgoing",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"lint", "test"},
+	}
+	if code := Invoke(inv); code != 3 {
+		t.Fatalf("expected 3, but got %v, stderr: %q", code, stderr)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected test not to run, but got %q", stdout)
+	}
+}
+
+func TestDepsCycle(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "testdata/cycle",
+		Stdout: io.Discard,
+		Stderr: stderr,
+		Args:   []string{"build"},
+	}
+	if code := Invoke(inv); code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	expected := "Error: dependency cycle: Build -> Generate -> Build\n"
+	if actual := stderr.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "testdata/hooks",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"build", "broken"},
+	}
+	if code := Invoke(inv); code != 1 {
+		t.Fatalf("expected 1, but got %v, stderr: %q", code, stderr)
+	}
+	expected := `before Build
+before compile("server")
+compile server
+after compile("server") <nil>
+build
+after Build <nil>
+before Broken
+failed Broken broken
+after Broken broken
+`
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
+
+func TestJobsCompiled(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	name := filepath.Join(t.TempDir(), "mage_out")
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	inv := Invocation{
+		Dir:        "testdata/jobs",
+		Stdout:     stdout,
+		Stderr:     stderr,
+		CompileOut: name,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr: %s", code, stderr)
+	}
+	stdout.Reset()
+	cmd := exec.CommandContext(context.Background(), name, "-j", "1", "parallel")
+	cmd.Env = os.Environ()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running compiled binary failed: %v, stderr: %s", err, stderr)
+	}
+	expected := "jobs=1 most=1\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
 func TestParseHelp(t *testing.T) {
 	buf := &bytes.Buffer{}
 	_, _, err := Parse(io.Discard, buf, []string{"-h"})
@@ -2171,3 +2529,443 @@ func fileData(file string) (exeType, archSize, error) {
 	}
 	return -1, -1, errors.New("unrecognized executable format")
 }
+
+func TestHiddenDefaultTarget(t *testing.T) {
+	expected := "Targets:\n  build    builds the project.\n"
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/hiddendefault",
+		Stdout: stdout,
+		Stderr: stderr,
+		List:   true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	// listed by the compiled magefile
+	stdout.Reset()
+	inv.List = false
+	inv.Args = []string{"-l"}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	// the hidden default target still runs.
+	stdout.Reset()
+	inv.Args = nil
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != "all\n" {
+		t.Fatalf("expected the default target to run, but got %q", actual)
+	}
+}
+
+func TestHiddenAndInternalTargets(t *testing.T) {
+	expected := "Targets:\n  build    builds the project.\n"
+
+	// listed without compiling
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/hidden",
+		Stdout: stdout,
+		Stderr: stderr,
+		List:   true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	// listed by the compiled magefile
+	stdout.Reset()
+	inv.List = false
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	stdout.Reset()
+	inv.Autocomplete = true
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != "build\n" {
+		t.Fatalf("expected only build to be completed, but got %q", actual)
+	}
+	inv.Autocomplete = false
+
+	// hidden targets can still be run, and internal ones only as dependencies.
+	stdout.Reset()
+	inv.Args = []string{"debug", "build"}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != "debugging\nsetting up\nbuilding\n" {
+		t.Fatalf("unexpected output %q", actual)
+	}
+
+	stderr.Reset()
+	inv.Args = []string{"setup"}
+	if code := Invoke(inv); code != 2 {
+		t.Fatalf("expected to exit with code 2, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stderr.String(); !strings.Contains(actual, `Unknown target specified: "setup"`) {
+		t.Fatalf("expected an unknown target error, but got %q", actual)
+	}
+}
+
+func TestListGroups(t *testing.T) {
+	expected := `
+Targets:
+  build    builds the project.
+  test     runs the tests.
+
+Danger zone:
+  deploy:production    deploys to production.
+
+Docs:
+  docs:serve    serves the documentation.
+
+Release:
+  deploy:staging    deploys to staging.
+  tag               tags a release.
+`[1:]
+
+	// listed without compiling
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/groups",
+		Stdout: stdout,
+		Stderr: stderr,
+		List:   true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+
+	// listed by the compiled magefile
+	stdout.Reset()
+	inv.List = false
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestDeprecatedTarget(t *testing.T) {
+	expected := "Targets:\n  build      builds the project.\n  compile    compiles the project. (deprecated)\n"
+
+	// listed without compiling
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/deprecated",
+		Stdout: stdout,
+		Stderr: stderr,
+		List:   true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	// listed by the compiled magefile
+	stdout.Reset()
+	inv.List = false
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	stdout.Reset()
+	inv.Help = true
+	inv.Args = []string{"compile"}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	expected = "Compile compiles the project.\n\nDeprecated: Use build instead.\n\nUsage:\n\n\tmage compile\n\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%q\n\ngot:\n%q", expected, actual)
+	}
+
+	stdout.Reset()
+	inv.Help = false
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	if actual := stdout.String(); actual != "building\n" {
+		t.Fatalf("unexpected output %q", actual)
+	}
+	if actual := stderr.String(); actual != "Warning: target \"compile\" is deprecated: Use build instead.\n" {
+		t.Fatalf("expected a deprecation warning, but got %q", actual)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/listjson",
+		Stdout: stdout,
+		Stderr: stderr,
+		List:   true,
+		JSON:   true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	var actual jsonListing
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("invalid JSON %s: %v", stdout, err)
+	}
+	file, err := filepath.Abs("testdata/listjson/magefile.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := jsonListing{
+		Description: "Builds things.",
+		Default:     "build",
+		Targets: []jsonTarget{
+			{
+				Name:     "build",
+				Function: "Build",
+				Synopsis: "builds the project.",
+				Comment:  "Build builds the project.",
+				Args:     []jsonArg{},
+				Aliases:  []string{"b"},
+				Default:  true,
+				File:     file,
+				Line:     16,
+				Column:   6,
+			},
+			{
+				Name:     "deploy",
+				Function: "Deploy",
+				Synopsis: "deploys the project.",
+				Comment:  "Deploy deploys the project.",
+				Args: []jsonArg{
+					{Name: "env", Type: "string", Choices: []string{"dev", "prod"}},
+					{Name: "replicas", Type: "int", Optional: true, Comment: "how many replicas to run", Default: "3", Env: "REPLICAS"},
+				},
+				File:   file,
+				Line:   21,
+				Column: 6,
+			},
+			{
+				Name:       "docs:serve",
+				Function:   "Serve",
+				Namespace:  "Docs",
+				Synopsis:   "serves the documentation.",
+				Comment:    "Serve serves the documentation.",
+				Args:       []jsonArg{},
+				Hidden:     true,
+				Deprecated: "Use a web server.",
+				File:       file,
+				Line:       37,
+				Column:     13,
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%#v\n\ngot:\n%#v", expected, actual)
+	}
+}
+
+func TestAutocompleteJSON(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:          "./testdata/listjson",
+		Stdout:       stdout,
+		Stderr:       stderr,
+		Autocomplete: true,
+		JSON:         true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	var actual []jsonCompletion
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("invalid JSON %s: %v", stdout, err)
+	}
+	expected := []jsonCompletion{
+		{Name: "b", Synopsis: "builds the project."},
+		{Name: "build", Synopsis: "builds the project."},
+		{Name: "deploy", Synopsis: "deploys the project."},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %#v, but got %#v", expected, actual)
+	}
+
+	stdout.Reset()
+	inv.Args = []string{"deploy"}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	actual = nil
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("invalid JSON %s: %v", stdout, err)
+	}
+	expected = []jsonCompletion{{Name: "dev"}, {Name: "prod"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %#v, but got %#v", expected, actual)
+	}
+}
+
+func TestListJSONHashFast(t *testing.T) {
+	// compile the magefile, so there's a binary mage could run.
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/listjson",
+		Stdout: io.Discard,
+		Stderr: stderr,
+		Args:   []string{"build"},
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+
+	stdout := &bytes.Buffer{}
+	inv = Invocation{
+		Dir:      "./testdata/listjson",
+		Stdout:   stdout,
+		Stderr:   stderr,
+		List:     true,
+		JSON:     true,
+		HashFast: true,
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	var listing jsonListing
+	if err := json.Unmarshal(stdout.Bytes(), &listing); err != nil {
+		t.Fatalf("invalid JSON %s: %v", stdout, err)
+	}
+	if len(listing.Targets) != 3 {
+		t.Fatalf("expected 3 targets, but got %#v", listing.Targets)
+	}
+
+	stdout.Reset()
+	inv.List = false
+	inv.Autocomplete = true
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	var completions []jsonCompletion
+	if err := json.Unmarshal(stdout.Bytes(), &completions); err != nil {
+		t.Fatalf("invalid JSON %s: %v", stdout, err)
+	}
+	if len(completions) != 3 {
+		t.Fatalf("expected 3 completions, but got %#v", completions)
+	}
+}
+
+func TestJSONWithoutList(t *testing.T) {
+	_, _, err := Parse(io.Discard, io.Discard, []string{"-json", "build"})
+	if err == nil || err.Error() != "-json only applies to -l and -autocomplete" {
+		t.Fatalf("expected an error about -json, but got %v", err)
+	}
+}
+
+func TestDocsMarkdown(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/docs",
+		Stdout: stdout,
+		Stderr: stderr,
+		Docs:   "markdown",
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	expected := "# mage\n\n" +
+		"Builds and deploys the project.\n\n" +
+		"The default target is `build`.\n\n" +
+		"## Targets\n\n" +
+		"### build\n\n" +
+		"Build builds the project.\n\n" +
+		"```plain\nmage build\n```\n\n" +
+		"Aliases: `b`\n\n" +
+		"### deploy\n\n" +
+		"Deploy deploys the project.\n\n" +
+		"```plain\nmage deploy <env> [<flags>]\n```\n\n" +
+		"Arguments:\n\n" +
+		"- `<env> dev|prod`\n\n" +
+		"Flags:\n\n" +
+		"- `-replicas=<int>`: how many replicas to run (env: $REPLICAS, default: 3)\n" +
+		"- `-dryRun=<bool>`\n\n" +
+		"## Namespace `docs`\n\n" +
+		"### docs:serve\n\n" +
+		"Serve serves the documentation.\n\n" +
+		"**Deprecated:** Use a web server.\n\n" +
+		"```plain\nmage docs:serve\n```\n"
+	if actual := stdout.String(); actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestDocsMan(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:    "./testdata/docs",
+		Stdout: stdout,
+		Stderr: stderr,
+		Docs:   "man",
+	}
+	if code := Invoke(inv); code != 0 {
+		t.Fatalf("expected to exit with code 0, but got %v, stderr:\n%s", code, stderr)
+	}
+	actual := stdout.String()
+	for _, s := range []string{
+		".TH MAGE 1\n",
+		".SH DESCRIPTION\nBuilds and deploys the project.\n.PP\nThe default target is \\fBbuild\\fR.\n",
+		".SH \"TARGETS\"\n.SS build\n",
+		".nf\nmage deploy <env> [<flags>]\n.fi\n",
+		".TP\n.B \\-replicas=<int>\nhow many replicas to run (env: $REPLICAS, default: 3)\n",
+		".SH \"NAMESPACE docs\"\n.SS docs:serve\n",
+		".PP\nDeprecated: Use a web server.\n",
+	} {
+		if !strings.Contains(actual, s) {
+			t.Errorf("expected man page to contain %q", s)
+		}
+	}
+	if strings.Contains(actual, "debug") {
+		t.Errorf("expected hidden target to be left out of man page")
+	}
+	if t.Failed() {
+		t.Logf("man page:\n%s", actual)
+	}
+}
+
+func TestDocsBadFormat(t *testing.T) {
+	_, _, err := Parse(io.Discard, io.Discard, []string{"-docs", "pdf"})
+	if err == nil || err.Error() != `unknown -docs format "pdf", must be one of: markdown, man` {
+		t.Fatalf("expected an error about the format, but got %v", err)
+	}
+}
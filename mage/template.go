@@ -10,6 +10,7 @@ package main
 
 import (
 	_context "context"
+	_json "encoding/json"
 	_flag "flag"
 	_fmt "fmt"
 	_io "io"
@@ -34,6 +35,9 @@ func main() {
 		List          bool          // print out a list of targets
 		Help          bool          // print out help for a specific target
 		Timeout       _time.Duration // set a timeout to running the targets
+		Jobs          int           // maximum number of dependencies to run at the same time
+		FailFast      bool          // cancel the remaining dependencies as soon as one fails
+		KeepGoing     bool          // run all targets even if some fail
 		Args          []string      // args contain the non-flag command-line arguments
 	}
 
@@ -62,6 +66,19 @@ func main() {
 		}
 		return d
 	}
+
+	parseInt := func(env string) int {
+		val := _os.Getenv(env)
+		if val == "" {
+			return 0
+		}
+		i, err := _strconv.Atoi(val)
+		if err != nil {
+			_log.Printf("warning: environment variable %s is not a valid int value: %v", env, val)
+			return 0
+		}
+		return i
+	}
 	args := arguments{}
 	fs := _flag.FlagSet{}
 	fs.SetOutput(_os.Stdout)
@@ -71,6 +88,9 @@ func main() {
 	fs.BoolVar(&args.List, "l", parseBool("MAGEFILE_LIST"), "list targets for this binary")
 	fs.BoolVar(&args.Help, "h", parseBool("MAGEFILE_HELP"), "print out help for a specific target")
 	fs.DurationVar(&args.Timeout, "t", parseDuration("MAGEFILE_TIMEOUT"), "timeout in duration parsable format (e.g. 5m30s)")
+	fs.IntVar(&args.Jobs, "j", parseInt("MAGEFILE_JOBS"), "run at most this many dependencies at the same time")
+	fs.BoolVar(&args.FailFast, "failfast", parseBool("MAGEFILE_FAILFAST"), "cancel the remaining dependencies as soon as one fails")
+	fs.BoolVar(&args.KeepGoing, "k", parseBool("MAGEFILE_KEEPGOING"), "keep going and run all targets even if some fail")
 	fs.Usage = func() {
 		_fmt.Fprintf(_os.Stdout, ` + "`" + `
 %s [options] [target]
@@ -80,7 +100,12 @@ Commands:
   -h    show this help
 
 Options:
+  -failfast
+        cancel the remaining dependencies as soon as one fails
   -h    show description of a target
+  -j <int>
+        run at most this many dependencies at the same time
+  -k    keep going and run all targets even if some fail
   -t <string>
         timeout in duration parsable format (e.g. 5m30s)
   -v    show verbose output when running targets
@@ -103,13 +128,23 @@ Options:
 		{{- end}}
 		{{- $default := .DefaultFunc}}
 		targets := map[string]string{
-		{{- range .Funcs}}
-			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .Synopsis}},
+		{{- range .Funcs}}{{if not .Hidden}}
+			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .ListSynopsis}},
+		{{- end}}{{end}}
+		{{- range .Imports}}{{$imp := .}}
+			{{- range .Info.Funcs}}{{if not .Hidden}}
+			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .ListSynopsis}},
+			{{- end}}{{end}}
 		{{- end}}
+		}
+		groups := map[string]string{
+		{{- range .Funcs}}{{if and (not .Hidden) .Group}}
+			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .Group}},
+		{{- end}}{{end}}
 		{{- range .Imports}}{{$imp := .}}
-			{{- range .Info.Funcs}}
-			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .Synopsis}},
-			{{- end}}
+			{{- range .Info.Funcs}}{{if and (not .Hidden) .Group}}
+			"{{lowerFirst .TargetName}}{{if and (eq .Name $default.Name) (eq .Receiver $default.Receiver)}}*{{end}}": {{printf "%q" .Group}},
+			{{- end}}{{end}}
 		{{- end}}
 		}
 
@@ -119,13 +154,37 @@ Options:
 		}
 		_sort.Strings(keys)
 
-		_fmt.Println("Targets:")
-		w := _tabwriter.NewWriter(_os.Stdout, 0, 4, 4, ' ', 0)
+		// targets without a group are listed first, then each group under
+		// its own heading.
+		byGroup := map[string][]string{}
+		var groupNames []string
 		for _, name := range keys {
-			_fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+			g := groups[name]
+			if _, ok := byGroup[g]; !ok && g != "" {
+				groupNames = append(groupNames, g)
+			}
+			byGroup[g] = append(byGroup[g], name)
+		}
+		_sort.Strings(groupNames)
+
+		w := _tabwriter.NewWriter(_os.Stdout, 0, 4, 4, ' ', 0)
+		if len(byGroup[""]) > 0 || len(groupNames) == 0 {
+			_fmt.Fprintln(w, "Targets:")
+			for _, name := range byGroup[""] {
+				_fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+			}
+		}
+		for i, g := range groupNames {
+			if i > 0 || len(byGroup[""]) > 0 {
+				_fmt.Fprintln(w)
+			}
+			_fmt.Fprintf(w, "%s:\n", g)
+			for _, name := range byGroup[g] {
+				_fmt.Fprintf(w, "  %v\t%v\n", printName(name), targets[name])
+			}
 		}
 		err := w.Flush()
-		{{- if .DefaultFunc.Name}}
+		{{- if and .DefaultFunc.Name (not .DefaultFunc.Hidden)}}
 			if err == nil {
 				_fmt.Println("\n* default target")
 			}
@@ -154,9 +213,23 @@ Options:
 		return ctx, ctxCancel
 	}
 
-	runTarget := func(logger *_log.Logger, fn func(_context.Context) error) interface{} {
+	// errSkipped is returned for targets that aren't run in keep-going mode
+	// because the context was already cancelled by an interrupt or timeout.
+	errSkipped := _fmt.Errorf("skipped")
+
+	runTarget := func(logger *_log.Logger, name string, fn func(_context.Context) error) interface{} {
+		{{- if .Hooks}}
+		// run the hooks declared by the magefile around the target.
+		target := fn
+		fn = func(ctx _context.Context) error {
+			return Hooks.Run(ctx, name, target)
+		}
+		{{- end}}
 		var err interface{}
 		ctx, cancel := getContext()
+		if args.KeepGoing && ctx.Err() != nil {
+			return errSkipped
+		}
 		d := make(chan interface{})
 		go func() {
 			defer func() {
@@ -201,20 +274,46 @@ Options:
 	// variable error.
 	_ = runTarget
 
+	exitStatus := func(err interface{}) int {
+		if err == nil {
+			return 0
+		}
+		type code interface {
+			ExitStatus() int
+		}
+		if c, ok := err.(code); ok {
+			return c.ExitStatus()
+		}
+		return 1
+	}
+
 	handleError := func(logger *_log.Logger, err interface{}) {
 		if err != nil {
 			logger.Printf("Error: %+v\n", err)
-			type code interface {
-				ExitStatus() int
-			}
-			if c, ok := err.(code); ok {
-				_os.Exit(c.ExitStatus())
-			}
-			_os.Exit(1)
+			_os.Exit(exitStatus(err))
 		}
 	}
 	_ = handleError
 
+	// In keep-going mode the result of each target is recorded instead of
+	// exiting on the first failure, and all of them are reported at the end.
+	type targetResult struct {
+		name string
+		err  interface{}
+	}
+	var results []targetResult
+	targetDone := func(logger *_log.Logger, name string, err interface{}) {
+		if !args.KeepGoing {
+			handleError(logger, err)
+			return
+		}
+		if err != nil && err != errSkipped {
+			logger.Printf("Error: %+v\n", err)
+		}
+		results = append(results, targetResult{name: name, err: err})
+	}
+	_ = targetDone
+
 	// Set MAGEFILE_VERBOSE so mg.Verbose() reflects the flag value.
 	if args.Verbose {
 		_os.Setenv("MAGEFILE_VERBOSE", "1")
@@ -222,11 +321,54 @@ Options:
 		_os.Setenv("MAGEFILE_VERBOSE", "0")
 	}
 
+	// Set MAGEFILE_JOBS so mg.Jobs() reflects the flag value.
+	_os.Setenv("MAGEFILE_JOBS", _strconv.Itoa(args.Jobs))
+
+	// Set MAGEFILE_FAILFAST so mg.FailFast() reflects the flag value.
+	if args.FailFast {
+		_os.Setenv("MAGEFILE_FAILFAST", "1")
+	} else {
+		_os.Setenv("MAGEFILE_FAILFAST", "0")
+	}
+
 	_log.SetFlags(0)
 	if !args.Verbose {
 		_log.SetOutput(_io.Discard)
 	}
 	logger := _log.New(_os.Stderr, "", 0)
+
+	// recordTiming appends how long a top level target took to run to the
+	// file mage reads the timings summary from.
+	recordTiming := func(name, target string, start _time.Time, err interface{}) {
+		path := _os.Getenv("MAGEFILE_TIMINGS_FILE")
+		if path == "" || err == errSkipped {
+			return
+		}
+		record := map[string]interface{}{
+			"name":   name,
+			"target": target,
+			"failed": err != nil,
+			"start":  start,
+			"end":    _time.Now(),
+		}
+		if err != nil {
+			record["error"] = _fmt.Sprint(err)
+		}
+		b, jerr := _json.Marshal(record)
+		if jerr != nil {
+			logger.Println("warning: can't record timings:", jerr)
+			return
+		}
+		f, ferr := _os.OpenFile(path, _os.O_WRONLY|_os.O_APPEND|_os.O_CREATE, 0o600)
+		if ferr != nil {
+			logger.Println("warning: can't record timings:", ferr)
+			return
+		}
+		_, _ = f.Write(append(b, '\n'))
+		_ = f.Close()
+	}
+	_ = recordTiming
+
 	if args.List {
 		if err := list(); err != nil {
 			_log.Println(err)
@@ -246,8 +388,14 @@ Options:
 				{{if ne .Comment "" -}}
 				_fmt.Println({{printf "%q" .Comment}})
 				_fmt.Println()
+				{{end -}}
+				{{if ne .Deprecated "" -}}
+				_fmt.Println({{printf "%q" (printf "Deprecated: %s" .Deprecated)}})
+				_fmt.Println()
 				{{end}}
-				_fmt.Print("Usage:\n\n\t{{$.BinaryName}} {{lower .TargetName}}{{range .RequiredArgs}} <{{.Name}}>{{end}}{{if .MultipleOptionalArgs}} [<flags>]{{else}}{{range .OptionalArgs}} [-{{.Name}}=<{{.Type}}>]{{end}}{{end}}\n\n")
+				_fmt.Print("Usage:\n\n\t{{$.BinaryName}} {{lower .TargetName}}{{range .RequiredArgs}} <{{.Name}}>{{end}}{{if .MultipleOptionalArgs}} [<flags>]{{else}}{{range .OptionalArgs}} [-{{.Name}}=<{{.ValueLabel}}>]{{end}}{{end}}\n\n")
+				{{if .ShowArgDocs}}_fmt.Print({{printf "%q" .ArgDocsString}})
+				{{end -}}
 				{{if .ShowFlagDocs}}_fmt.Print({{printf "%q" .FlagDocsString}})
 				{{end -}}
 				var aliases []string
@@ -268,8 +416,14 @@ Options:
 				{{if ne .Comment "" -}}
 				_fmt.Println({{printf "%q" .Comment}})
 				_fmt.Println()
+				{{end -}}
+				{{if ne .Deprecated "" -}}
+				_fmt.Println({{printf "%q" (printf "Deprecated: %s" .Deprecated)}})
+				_fmt.Println()
 				{{end}}
-				_fmt.Print("Usage:\n\n\t{{$.BinaryName}} {{lower .TargetName}}{{range .RequiredArgs}} <{{.Name}}>{{end}}{{if .MultipleOptionalArgs}} [<flags>]{{else}}{{range .OptionalArgs}} [-{{.Name}}=<{{.Type}}>]{{end}}{{end}}\n\n")
+				_fmt.Print("Usage:\n\n\t{{$.BinaryName}} {{lower .TargetName}}{{range .RequiredArgs}} <{{.Name}}>{{end}}{{if .MultipleOptionalArgs}} [<flags>]{{else}}{{range .OptionalArgs}} [-{{.Name}}=<{{.ValueLabel}}>]{{end}}{{end}}\n\n")
+				{{if .ShowArgDocs}}_fmt.Print({{printf "%q" .ArgDocsString}})
+				{{end -}}
 				{{if .ShowFlagDocs}}_fmt.Print({{printf "%q" .FlagDocsString}})
 				{{end -}}
 				var aliases []string
@@ -290,6 +444,11 @@ Options:
 				_os.Exit(2)
 		}
 	}
+	{{- if .Hooks}}
+
+	// run the hooks declared by the magefile around every dependency too.
+	Hooks.Register()
+	{{- end}}
 	if len(args.Args) < 1 {
 	{{- if .DefaultFunc.Name}}
 		ignoreDefault, _ := _strconv.ParseBool(_os.Getenv("MAGEFILE_IGNOREDEFAULT"))
@@ -300,7 +459,12 @@ Options:
 			}
 			return
 		}
+		{{- with .DefaultFunc.DeprecationWarning}}
+		logger.Println({{printf "%q" .}})
+		{{- end}}
+		start := _time.Now()
 		{{.DefaultFunc.ExecCode}}
+		recordTiming("{{funcName .DefaultFunc}}", "{{.DefaultFunc.TargetName}}", start, ret)
 		handleError(logger, ret)
 		return
 	{{- else}}
@@ -336,8 +500,13 @@ Options:
 				if args.Verbose {
 					logger.Println("Running target:", "{{.TargetName}}")
 				}
+				{{- with .DeprecationWarning}}
+				logger.Println({{printf "%q" .}})
+				{{- end}}
+				start := _time.Now()
 				{{.ExecCode}}
-				handleError(logger, ret)
+				recordTiming("{{funcName .}}", "{{.TargetName}}", start, ret)
+				targetDone(logger, "{{lowerFirst .TargetName}}", ret)
 		{{- end}}
 		{{range .Imports}}
 		{{$imp := .}}
@@ -353,8 +522,13 @@ Options:
 					if args.Verbose {
 						logger.Println("Running target:", "{{.TargetName}}")
 					}
+					{{- with .DeprecationWarning}}
+					logger.Println({{printf "%q" .}})
+					{{- end}}
+					start := _time.Now()
 					{{.ExecCode}}
-					handleError(logger, ret)
+					recordTiming("{{funcName .}}", "{{.TargetName}}", start, ret)
+					targetDone(logger, "{{lowerFirst .TargetName}}", ret)
 			{{- end}}
 		{{- end}}
 		default:
@@ -362,6 +536,42 @@ Options:
 			_os.Exit(2)
 		}
 	}
+
+	if args.KeepGoing {
+		// the summary is only printed if something failed, or with -v, so
+		// that it isn't noise after every successful run.
+		exit, skipped := 0, false
+		var summary _strings.Builder
+		w := _tabwriter.NewWriter(&summary, 0, 4, 4, ' ', 0)
+		for _, r := range results {
+			result := "passed"
+			switch {
+			case r.err == errSkipped:
+				result = "skipped"
+				skipped = true
+			case r.err != nil:
+				code := exitStatus(r.err)
+				result = _fmt.Sprintf("failed (exit code %d)", code)
+				if exit == 0 {
+					exit = code
+				} else if exit != code {
+					// different exit codes, nothing more we can do than 1.
+					exit = 1
+				}
+			}
+			_fmt.Fprintf(w, "  %s\t%s\n", r.name, result)
+		}
+		_ = w.Flush()
+		if exit == 0 && skipped {
+			exit = 1
+		}
+		if exit != 0 || args.Verbose {
+			logger.Print("\nSummary:\n", summary.String())
+		}
+		if exit != 0 {
+			_os.Exit(exit)
+		}
+	}
 }
 
 
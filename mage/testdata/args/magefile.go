@@ -54,3 +54,19 @@ func HasDep() {
 func DoubleIt(f float64) {
 	fmt.Printf("%.1f * 2 = %.1f\n", f, f*2)
 }
+
+func Sum(nums []int) {
+	total := 0
+	for _, n := range nums {
+		total += n
+	}
+	fmt.Println("sum", total)
+}
+
+func Test(pkgs []string) {
+	fmt.Println("testing", len(pkgs), pkgs)
+}
+
+func HasSliceDep() {
+	mg.Deps(mg.F(Test, []string{"./mg", "./sh"}), mg.F(DoubleIt, 1.5))
+}
@@ -0,0 +1,14 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "github.com/magefile/mage/mg"
+
+func Build() {
+	mg.Deps(Generate)
+}
+
+func Generate() {
+	mg.Deps(Build)
+}
@@ -0,0 +1,31 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Greet greets someone a number of times.
+//
+// mage:default count=3
+// mage:default greeting=Hello there
+// mage:default wait=1ms
+func Greet(
+	name string,
+	// how many times to greet
+	// mage:env GREET_COUNT
+	count *int,
+	greeting *string,
+	wait *time.Duration,
+	names *[]string, // more people to greet
+) {
+	people := name
+	if names != nil {
+		people = strings.Join(append([]string{name}, *names...), ", ")
+	}
+	fmt.Printf("%s, %s! x%d after %v\n", *greeting, people, *count, *wait)
+}
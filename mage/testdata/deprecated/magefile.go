@@ -0,0 +1,18 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "fmt"
+
+// Build builds the project.
+func Build() {
+	fmt.Println("building")
+}
+
+// Compile compiles the project.
+//
+// Deprecated: Use build instead.
+func Compile() {
+	Build()
+}
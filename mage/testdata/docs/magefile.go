@@ -0,0 +1,41 @@
+//go:build mage
+// +build mage
+
+// Builds and deploys the project.
+package main
+
+import "github.com/magefile/mage/mg"
+
+var Default = Build
+
+var Aliases = map[string]interface{}{
+	"b": Build,
+}
+
+// Build builds the project.
+func Build() {}
+
+// Deploy deploys the project.
+//
+// mage:default replicas=3
+func Deploy(
+	env string, // mage:enum dev prod
+	// how many replicas to run
+	// mage:env REPLICAS
+	replicas *int,
+	dryRun *bool,
+) {
+}
+
+// Debug prints the build environment.
+//
+// mage:hidden
+func Debug() {}
+
+// Docs has targets for the documentation.
+type Docs mg.Namespace
+
+// Serve serves the documentation.
+//
+// Deprecated: Use a web server.
+func (Docs) Serve() {}
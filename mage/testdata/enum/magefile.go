@@ -0,0 +1,24 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "fmt"
+
+// Deploy deploys to an environment.
+func Deploy(
+	// the environment to deploy to
+	// mage:enum dev staging prod
+	env string,
+	region *string, // mage:enum us,eu
+) {
+	if region != nil {
+		fmt.Printf("deploying to %s in %s\n", env, *region)
+	} else {
+		fmt.Printf("deploying to %s\n", env)
+	}
+}
+
+func Release(tag string) {
+	fmt.Println("releasing", tag)
+}
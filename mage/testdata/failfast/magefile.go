@@ -0,0 +1,32 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/magefile/mage/mg"
+)
+
+// Build fails, which cancels Slow when failing fast.
+func Build() {
+	mg.Deps(Fail, Slow)
+}
+
+func Fail() error {
+	return mg.Fatal(3, "fail")
+}
+
+func Slow(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		fmt.Println("slow cancelled")
+		return ctx.Err()
+	case <-time.After(5 * time.Second):
+		fmt.Println("slow finished")
+		return nil
+	}
+}
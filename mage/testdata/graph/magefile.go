@@ -0,0 +1,27 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"errors"
+
+	"github.com/magefile/mage/mg"
+)
+
+func Build() {
+	mg.Deps(Generate, mg.F(Compile, "server"))
+}
+
+func Generate() {}
+
+func Compile(what string) {
+	mg.Deps(Generate)
+}
+
+func NoDeps() {}
+
+func Fail() error {
+	mg.Deps(Generate)
+	return errors.New("boom")
+}
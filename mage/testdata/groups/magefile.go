@@ -0,0 +1,40 @@
+//go:build mage
+// +build mage
+
+package main
+
+//mage:namespacegroups
+
+import (
+	"github.com/magefile/mage/mg"
+)
+
+// Build builds the project.
+func Build() {}
+
+// Test runs the tests.
+func Test() {}
+
+// Tag tags a release.
+//
+// mage:group Release
+func Tag() {}
+
+// Docs has targets for the documentation.
+type Docs mg.Namespace
+
+// Serve serves the documentation.
+func (Docs) Serve() {}
+
+// Deploy has targets to deploy releases.
+//
+// mage:group Release
+type Deploy mg.Namespace
+
+// Staging deploys to staging.
+func (Deploy) Staging() {}
+
+// Production deploys to production.
+//
+// mage:group Danger zone
+func (Deploy) Production() {}
@@ -0,0 +1,30 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/magefile/mage/mg"
+)
+
+// Build builds the project.
+func Build() {
+	mg.Deps(Setup)
+	fmt.Println("building")
+}
+
+// Debug prints debugging information.
+//
+// mage:hidden
+func Debug() {
+	fmt.Println("debugging")
+}
+
+// Setup prepares the build.
+//
+// mage:internal
+func Setup() {
+	fmt.Println("setting up")
+}
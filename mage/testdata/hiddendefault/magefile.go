@@ -0,0 +1,20 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "fmt"
+
+var Default = All
+
+// All builds and tests the project.
+//
+// mage:hidden
+func All() {
+	fmt.Println("all")
+}
+
+// Build builds the project.
+func Build() {
+	fmt.Println("building")
+}
@@ -0,0 +1,38 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/magefile/mage/mg"
+)
+
+var Hooks = mg.Hooks{
+	Before: func(_ context.Context, name string) error {
+		fmt.Println("before", name)
+		return nil
+	},
+	After: func(_ context.Context, name string, err error) {
+		fmt.Println("after", name, err)
+	},
+	OnFailure: func(_ context.Context, name string, err error) {
+		fmt.Println("failed", name, err)
+	},
+}
+
+func Build() {
+	mg.Deps(mg.F(compile, "server"))
+	fmt.Println("build")
+}
+
+func compile(what string) {
+	fmt.Println("compile", what)
+}
+
+func Broken() error {
+	return errors.New("broken")
+}
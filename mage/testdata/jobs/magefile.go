@@ -0,0 +1,38 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/magefile/mage/mg"
+)
+
+var (
+	mu      sync.Mutex
+	running int
+	most    int
+)
+
+func work(int) {
+	mu.Lock()
+	running++
+	if running > most {
+		most = running
+	}
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	running--
+	mu.Unlock()
+}
+
+// Parallel runs several dependencies and reports how many ran at once.
+func Parallel() {
+	mg.Deps(mg.F(work, 1), mg.F(work, 2), mg.F(work, 3), mg.F(work, 4))
+	fmt.Printf("jobs=%s most=%d\n", os.Getenv("MAGEFILE_JOBS"), most)
+}
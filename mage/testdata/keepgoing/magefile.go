@@ -0,0 +1,35 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/magefile/mage/mg"
+)
+
+func Lint() error {
+	return mg.Fatal(3, "lint failed")
+}
+
+func Test() {
+	fmt.Println("test ran")
+}
+
+func Vet() error {
+	return errors.New("vet failed")
+}
+
+// Slow waits until the context is done.
+func Slow(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
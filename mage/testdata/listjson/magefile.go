@@ -0,0 +1,37 @@
+//go:build mage
+// +build mage
+
+// Builds things.
+package main
+
+import "github.com/magefile/mage/mg"
+
+var Default = Build
+
+var Aliases = map[string]interface{}{
+	"b": Build,
+}
+
+// Build builds the project.
+func Build() {}
+
+// Deploy deploys the project.
+//
+// mage:default replicas=3
+func Deploy(
+	env string, // mage:enum dev prod
+	// how many replicas to run
+	// mage:env REPLICAS
+	replicas *int,
+) {
+}
+
+// Docs has targets for the documentation.
+type Docs mg.Namespace
+
+// Serve serves the documentation.
+//
+// mage:hidden
+//
+// Deprecated: Use a web server.
+func (Docs) Serve() {}
@@ -83,6 +83,15 @@ func Say(msg string, cap *bool, count *int) {
 	}
 }
 
+// Tag tags a release with optional labels.
+func Tag(version string, labels *[]string) {
+	if labels != nil {
+		fmt.Printf("tag %s %s\n", version, strings.Join(*labels, " "))
+	} else {
+		fmt.Printf("tag %s\n", version)
+	}
+}
+
 // Announce prints an announcement.
 func Announce(msg string) {
 	fmt.Printf("Announcement: %s\n", msg)
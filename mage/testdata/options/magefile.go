@@ -0,0 +1,31 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magefile/mage/mg"
+)
+
+// DeployOptions are the options of Deploy.
+type DeployOptions struct {
+	Region   string   // the region to deploy to
+	Replicas int      `default:"3"` // how many replicas to run
+	DryRun   bool     // only print what would be done
+	Tags     []string // tags for the release
+	internal string
+}
+
+// Deploy deploys to an environment.
+func Deploy(env string, opts DeployOptions) {
+	fmt.Printf("deploying to %s in %q with %d replicas, dry run %v, tags %s\n",
+		env, opts.Region, opts.Replicas, opts.DryRun, strings.Join(opts.Tags, ","))
+}
+
+// Staging deploys to staging.
+func Staging() {
+	mg.Deps(mg.F(Deploy, "staging", DeployOptions{Region: "us", Replicas: 1}))
+}
@@ -0,0 +1,65 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magefile/mage/mg"
+)
+
+// Platform is a GOOS/GOARCH pair.
+type Platform struct {
+	OS, Arch string
+}
+
+func (p *Platform) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("expected os/arch, got %q", text)
+	}
+	p.OS, p.Arch = parts[0], parts[1]
+	return nil
+}
+
+func (p Platform) MarshalText() ([]byte, error) {
+	return []byte(p.OS + "/" + p.Arch), nil
+}
+
+// LogLevel is how much to log.
+type LogLevel int
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown log level %q", text)
+	}
+	return nil
+}
+
+// Build builds for a platform.
+func Build(
+	platform Platform, // the platform to build for
+	level *LogLevel,
+) {
+	if level != nil {
+		fmt.Printf("building for %s on %s with log level %d\n", platform.OS, platform.Arch, *level)
+	} else {
+		fmt.Printf("building for %s on %s\n", platform.OS, platform.Arch)
+	}
+}
+
+// Deploy deploys to a platform.
+func Deploy(platform Platform) {
+	fmt.Printf("deploying to %s/%s\n", platform.OS, platform.Arch)
+}
+
+func All() {
+	mg.Deps(mg.F(Deploy, Platform{OS: "linux", Arch: "arm64"}))
+}
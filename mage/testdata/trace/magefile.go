@@ -0,0 +1,17 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"github.com/magefile/mage/mg"
+	"github.com/magefile/mage/sh"
+)
+
+func Build() {
+	mg.Deps(mg.F(Compile, "server"))
+}
+
+func Compile(name string) error {
+	return sh.Run("go", "env", "GOOS")
+}
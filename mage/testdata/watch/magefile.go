@@ -0,0 +1,73 @@
+//go:build mage
+// +build mage
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/target"
+)
+
+// Build records that it ran.
+//
+// mage:watch watched/*.txt
+func Build() error {
+	return record("build")
+}
+
+// Copy records that it ran, after checking its source.
+func Copy() error {
+	if _, err := target.Path("out.txt", "src.txt"); err != nil {
+		return err
+	}
+	return record("copy")
+}
+
+// Switch records that it ran, after checking the source named in use.txt.
+//
+// mage:watch use.txt
+func Switch() error {
+	src, err := os.ReadFile("use.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := target.Path("out.txt", strings.TrimSpace(string(src))); err != nil {
+		return err
+	}
+	return record("switch")
+}
+
+// Wait records that it started, and then that it was cancelled, or that it
+// finished once finish.txt exists.
+//
+// mage:watch trigger.txt
+func Wait(ctx context.Context) error {
+	if err := record("start"); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return record("cancelled")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if _, err := os.Stat("finish.txt"); err == nil {
+			return record("finished")
+		}
+	}
+}
+
+func record(s string) error {
+	f, err := os.OpenFile("runs.txt", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, s)
+	return err
+}
@@ -0,0 +1,156 @@
+package mage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
+)
+
+// readTimings reads the timing records the compiled magefile wrote, one JSON
+// object per line.
+func readTimings(r io.Reader) ([]internal.Timing, error) {
+	var timings []internal.Timing
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var t internal.Timing
+		if err := json.Unmarshal([]byte(line), &t); err != nil {
+			return nil, fmt.Errorf("malformed timing record %q: %w", line, err)
+		}
+		timings = append(timings, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Start.Before(timings[j].Start)
+	})
+	return timings, nil
+}
+
+// timingName returns the name to display for a target or dependency.
+func timingName(t internal.Timing) string {
+	if t.Target != "" {
+		return t.Target
+	}
+	return mg.DepNode{Name: t.Name, ID: t.ID}.String()
+}
+
+// formatDuration rounds durations so they're easy to compare at a glance.
+func formatDuration(d time.Duration) string {
+	if d >= time.Millisecond {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// timingsReport formats a summary of the wall time of each top level target
+// and dependency that ran, how often each dependency was served from the cache
+// of functions that already ran, and the critical path through the
+// dependencies of each target.
+func timingsReport(timings []internal.Timing) string {
+	type key struct{ name, id string }
+
+	// commands are only recorded for traces.
+	var funcs []internal.Timing
+	for _, t := range timings {
+		if t.Cmd == "" {
+			funcs = append(funcs, t)
+		}
+	}
+	timings = funcs
+
+	// the record of the call that actually ran each dependency, and the
+	// number of calls that found it had already run.
+	runs := map[key]internal.Timing{}
+	hits := map[key]int{}
+	for _, t := range timings {
+		if t.Target != "" {
+			continue
+		}
+		k := key{t.Name, t.ID}
+		if t.Cached {
+			hits[k]++
+		} else {
+			runs[k] = t
+		}
+	}
+
+	var buf strings.Builder
+	_, _ = fmt.Fprintln(&buf, "Timings:")
+	w := tabwriter.NewWriter(&buf, 0, 4, 4, ' ', 0)
+	for _, t := range timings {
+		if t.Cached {
+			continue
+		}
+		var notes []string
+		if n := hits[key{t.Name, t.ID}]; n > 0 && t.Target == "" {
+			notes = append(notes, fmt.Sprintf("cached %dx", n))
+		}
+		if t.Failed {
+			notes = append(notes, "failed")
+		}
+		d := formatDuration(t.End.Sub(t.Start))
+		if len(notes) > 0 {
+			d += " (" + strings.Join(notes, ", ") + ")"
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", timingName(t), d)
+	}
+	_ = w.Flush()
+
+	// The critical path follows, from each top level target, the dependency
+	// that finished last, since that's the one the caller was waiting on.
+	var paths []string
+	for _, root := range timings {
+		if root.Target == "" {
+			continue
+		}
+		path := []string{timingName(root)}
+		seen := map[key]bool{}
+		cur := root
+		for {
+			var next internal.Timing
+			found := false
+			for _, t := range timings {
+				if t.Target != "" || t.Start.Before(cur.Start) || t.Start.After(cur.End) {
+					continue
+				}
+				if t.ParentName != cur.Name || (cur.Target == "" && t.ParentID != cur.ID) {
+					continue
+				}
+				run, ok := runs[key{t.Name, t.ID}]
+				if !ok || seen[key{t.Name, t.ID}] {
+					continue
+				}
+				if !found || run.End.After(next.End) {
+					next = run
+					found = true
+				}
+			}
+			if !found {
+				break
+			}
+			seen[key{next.Name, next.ID}] = true
+			path = append(path, fmt.Sprintf("%s (%s)", timingName(next), formatDuration(next.End.Sub(next.Start))))
+			cur = next
+		}
+		path[0] = fmt.Sprintf("%s (%s)", path[0], formatDuration(root.End.Sub(root.Start)))
+		paths = append(paths, "  "+strings.Join(path, " -> "))
+	}
+	if len(paths) > 0 {
+		_, _ = fmt.Fprintln(&buf, "\nCritical path:")
+		_, _ = fmt.Fprintln(&buf, strings.Join(paths, "\n"))
+	}
+	return buf.String()
+}
@@ -0,0 +1,59 @@
+package mage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magefile/mage/internal"
+)
+
+func TestTimingsReport(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	timings := []internal.Timing{
+		{Name: "main.Build", Target: "build", Start: at(0), End: at(1500)},
+		{Name: "main.Generate", ID: "null", ParentName: "main.Build", Start: at(1), End: at(300)},
+		{Name: "main.Compile", ID: `["server"]`, ParentName: "main.Build", Start: at(1), End: at(1400)},
+		{Name: "main.Generate", ID: "null", ParentName: "main.Compile", ParentID: `["server"]`, Cached: true, Start: at(2), End: at(300)},
+		{Name: "main.Link", ID: "null", ParentName: "main.Compile", ParentID: `["server"]`, Start: at(300), End: at(1200), Failed: true},
+	}
+	actual := timingsReport(timings)
+	expected := `Timings:
+  build                1.5s
+  Generate             299ms (cached 1x)
+  Compile("server")    1.399s
+  Link                 900ms (failed)
+
+Critical path:
+  build (1.5s) -> Compile("server") (1.399s) -> Link (900ms)
+`
+	if actual != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
+
+func TestTimings(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	inv := Invocation{
+		Dir:     "testdata/graph",
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Args:    []string{"build"},
+		Timings: true,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	actual := stderr.String()
+	for _, s := range []string{"\nTimings:\n  Build ", "\n  Compile(\"server\") ", " (cached 1x)\n", "\nCritical path:\n  Build ("} {
+		if !strings.Contains(actual, s) {
+			t.Errorf("expected output to contain %q, but got:\n%s", s, actual)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package mage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
+)
+
+// The types below are just enough of the OTLP/JSON trace format to export the
+// spans of a run of mage, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+func stringAttr(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int) otlpKeyValue {
+	s := strconv.Itoa(value)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+// commandLine returns the command line of a command, with its arguments quoted
+// like package sh logs them.
+func commandLine(cmd string, args []string) string {
+	quoted := []string{cmd}
+	for _, a := range args {
+		quoted = append(quoted, strconv.Quote(a))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomID returns a random hex encoded ID of n bytes.
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// buildTrace turns the timings the compiled magefile recorded into a trace
+// with a span for each top level target, each dependency that ran and each
+// command run with package sh. Dependencies and commands are children of the
+// dependency that declared or ran them, or of the target that was running at
+// the time.
+func buildTrace(timings []internal.Timing) otlpTraces {
+	type key struct{ name, id string }
+
+	traceID := randomID(16)
+	var spans []otlpSpan
+	var targets []int
+	runs := map[key]int{}
+	var kept []internal.Timing
+	for _, t := range timings {
+		if t.Cached {
+			// the dependency already ran, and has a span for that.
+			continue
+		}
+		span := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            randomID(8),
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: unixNano(t.Start),
+			EndTimeUnixNano:   unixNano(t.End),
+		}
+		switch {
+		case t.Target != "":
+			span.Name = t.Target
+			span.Attributes = []otlpKeyValue{
+				stringAttr("mage.target", t.Target),
+				stringAttr("code.function", t.Name),
+			}
+			targets = append(targets, len(spans))
+		case t.Cmd != "":
+			span.Name = "exec " + t.Cmd
+			span.Attributes = []otlpKeyValue{
+				stringAttr("process.executable.name", t.Cmd),
+				intAttr("process.exit_code", t.ExitCode),
+			}
+			if len(t.Args) > 0 {
+				span.Attributes = append(span.Attributes, stringAttr("process.command_line", commandLine(t.Cmd, t.Args)))
+			}
+		default:
+			span.Name = mg.DepNode{Name: t.Name, ID: t.ID}.String()
+			span.Attributes = []otlpKeyValue{stringAttr("code.function", t.Name)}
+			if t.ID != "" && t.ID != "null" && t.ID != "[]" {
+				span.Attributes = append(span.Attributes, stringAttr("mage.args", t.ID))
+			}
+			runs[key{t.Name, t.ID}] = len(spans)
+		}
+		if t.Failed {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: t.Error}
+		}
+		spans = append(spans, span)
+		kept = append(kept, t)
+	}
+
+	for i, t := range kept {
+		if t.ParentName == "" {
+			continue
+		}
+		if p, ok := runs[key{t.ParentName, t.ParentID}]; ok && p != i {
+			spans[i].ParentSpanID = spans[p].SpanID
+			continue
+		}
+		// targets run one after another, so the one running at the time
+		// is the parent.
+		for _, p := range targets {
+			if !t.Start.Before(kept[p].Start) && !t.Start.After(kept[p].End) {
+				spans[i].ParentSpanID = spans[p].SpanID
+				break
+			}
+		}
+	}
+
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{stringAttr("service.name", "mage")}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/magefile/mage"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// exportTrace exports the trace of the given timings as OTLP/JSON to dest,
+// which is either the http(s) endpoint of a collector or a file.
+func exportTrace(dest string, timings []internal.Timing) error {
+	b, err := json.Marshal(buildTrace(timings))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(dest, "http://") && !strings.HasPrefix(dest, "https://") {
+		return os.WriteFile(dest, b, 0o644)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector at %s responded with %s", dest, resp.Status)
+	}
+	return nil
+}
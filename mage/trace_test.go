@@ -0,0 +1,152 @@
+package mage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
+)
+
+// spanParents returns the name of the parent of each span in the trace,
+// keyed by span name.
+func spanParents(t *testing.T, trace otlpTraces) map[string]string {
+	t.Helper()
+	if len(trace.ResourceSpans) != 1 || len(trace.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("expected a single scope of spans, but got %+v", trace)
+	}
+	spans := trace.ResourceSpans[0].ScopeSpans[0].Spans
+	names := map[string]string{}
+	for _, s := range spans {
+		names[s.SpanID] = s.Name
+	}
+	parents := map[string]string{}
+	for _, s := range spans {
+		if s.TraceID != spans[0].TraceID {
+			t.Errorf("expected all spans in trace %s, but %s is in %s", spans[0].TraceID, s.Name, s.TraceID)
+		}
+		parents[s.Name] = names[s.ParentSpanID]
+	}
+	return parents
+}
+
+func TestBuildTrace(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	timings := []internal.Timing{
+		{Name: "main.Build", Target: "build", Start: at(0), End: at(1500)},
+		{Name: "main.Generate", ID: "null", ParentName: "main.Build", Start: at(1), End: at(300)},
+		{Name: "main.Compile", ID: `["server"]`, ParentName: "main.Build", Start: at(1), End: at(1400)},
+		{Name: "main.Generate", ID: "null", ParentName: "main.Compile", ParentID: `["server"]`, Cached: true, Start: at(2), End: at(300)},
+		{Name: "exec", Cmd: "go", ParentName: "main.Compile", ParentID: `["server"]`, Start: at(300), End: at(1200), Failed: true, ExitCode: 2, Error: "exit status 2"},
+		{Name: "exec", Cmd: "git", ParentName: "main.Build", Start: at(1400), End: at(1450)},
+	}
+	trace := buildTrace(timings)
+	actual := spanParents(t, trace)
+	expected := map[string]string{
+		"build":             "",
+		"Generate":          "build",
+		`Compile("server")`: "build",
+		"exec go":           `Compile("server")`,
+		"exec git":          "build",
+	}
+	if len(actual) != len(expected) {
+		t.Errorf("expected spans %v, but got %v", expected, actual)
+	}
+	for name, parent := range expected {
+		if p, ok := actual[name]; !ok || p != parent {
+			t.Errorf("expected span %s with parent %q, but got %v", name, parent, actual)
+		}
+	}
+	for _, s := range trace.ResourceSpans[0].ScopeSpans[0].Spans {
+		if s.Name == "exec go" && (s.Status.Code != otlpStatusError || s.Status.Message != "exit status 2") {
+			t.Errorf("expected failed command to have error status, but got %+v", s.Status)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "trace.json")
+	inv := Invocation{
+		Dir:    "testdata/trace",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"build"},
+		Trace:  path,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace otlpTraces
+	if err := json.Unmarshal(b, &trace); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("GOOS")) {
+		t.Errorf("expected the arguments of commands to be left out of the trace, but got %s", b)
+	}
+	actual := spanParents(t, trace)
+	expected := map[string]string{
+		"Build":             "",
+		`Compile("server")`: "Build",
+		"exec go":           `Compile("server")`,
+	}
+	for name, parent := range expected {
+		if p, ok := actual[name]; !ok || p != parent {
+			t.Errorf("expected span %s with parent %q, but got %v", name, parent, actual)
+		}
+	}
+}
+
+func TestTraceArgs(t *testing.T) {
+	t.Setenv(mg.TraceArgsEnv, "1")
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "trace.json")
+	inv := Invocation{
+		Dir:    "testdata/trace",
+		Stdout: stdout,
+		Stderr: stderr,
+		Args:   []string{"build"},
+		Trace:  path,
+	}
+	code := Invoke(inv)
+	if code != 0 {
+		t.Fatalf("expected 0, but got %v, stderr: %q", code, stderr)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace otlpTraces
+	if err := json.Unmarshal(b, &trace); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range trace.ResourceSpans[0].ScopeSpans[0].Spans {
+		if s.Name != "exec go" {
+			continue
+		}
+		for _, a := range s.Attributes {
+			if a.Key == "process.command_line" {
+				if a.Value.StringValue == nil || *a.Value.StringValue != `go "env" "GOOS"` {
+					t.Fatalf("expected the command line of the command, but got %+v", a.Value)
+				}
+				return
+			}
+		}
+		t.Fatalf("expected the command line in the attributes of the command, but got %+v", s.Attributes)
+	}
+	t.Fatalf("expected a span for the command, but got %s", b)
+}
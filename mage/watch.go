@@ -0,0 +1,259 @@
+package mage
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
+	"github.com/magefile/mage/parse"
+)
+
+// watchInterval is how often mage -watch polls the watched files for changes,
+// and watchDebounce how long they must stay unchanged before the targets are
+// run again, so that saving many files at once runs them once.
+var (
+	watchInterval = 500 * time.Millisecond
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watch runs the compiled magefile like RunCompiled, and then runs it again
+// whenever the watched files change, until mage is interrupted.
+func watch(inv Invocation, exePath string, errlog *log.Logger) int {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	return runWatching(inv, exePath, errlog, sigCh)
+}
+
+// runWatching runs the compiled magefile, and runs it again whenever the
+// magefiles, the files matched by the mage:watch globs of the targets run, or
+// the sources the targets passed to package target change. A run that is still
+// going is interrupted first, and the magefiles are compiled again if they
+// changed. It returns the exit code of the last run once stop receives.
+func runWatching(inv Invocation, exePath string, errlog *log.Logger, stop <-chan os.Signal) int {
+	f, err := os.CreateTemp("", "mage-watch")
+	if err != nil {
+		errlog.Printf("can't create file for watched sources: %v", err)
+		return 1
+	}
+	watchFile := f.Name()
+	_ = f.Close()
+	defer func() { _ = os.Remove(watchFile) }()
+
+	var (
+		code   int
+		cancel = func() {}
+		done   chan int // receives the exit code of the run in progress, if any
+	)
+	start := func() {
+		// sources the targets no longer use aren't watched anymore.
+		if err := os.Truncate(watchFile, 0); err != nil {
+			errlog.Printf("can't reset watched sources: %v", err)
+		}
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan int, 1)
+		go func() {
+			done <- runCompiled(ctx, inv, exePath, errlog, mg.WatchFileEnv+"="+watchFile)
+		}()
+	}
+	defer func() { cancel() }()
+
+	globs := watchGlobs(inv, errlog)
+	patterns := func() []string {
+		sources, _ := internal.ReadWatch(watchFile)
+		return append(append([]string{}, globs...), sources...)
+	}
+	files := snapshot(patterns())
+	start()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	var changed time.Time // when the files last changed, if the targets haven't run since
+	for {
+		select {
+		case <-stop:
+			// an interrupt from the terminal reaches the compiled magefile
+			// too, so give it time to clean up before interrupting it.
+			if done != nil {
+				select {
+				case code = <-done:
+				case <-time.After(killTimeout):
+					cancel()
+					code = <-done
+				}
+			}
+			return code
+		case code = <-done:
+			done = nil
+		case <-ticker.C:
+			now := snapshot(patterns())
+			if files.changed(now) {
+				changed = time.Now()
+			}
+			files = now
+			if changed.IsZero() || time.Since(changed) < watchDebounce {
+				continue
+			}
+			changed = time.Time{}
+			cancel()
+			if done != nil {
+				code = <-done
+				done = nil
+			}
+			errlog.Println("Files changed, running again.")
+			if magefilesChanged(inv, exePath) {
+				var ok bool
+				exePath, code, ok = prepare(&inv, errlog)
+				if !ok {
+					// wait for the magefiles to be fixed.
+					exePath = ""
+					files = snapshot(patterns())
+					continue
+				}
+				globs = watchGlobs(inv, errlog)
+			}
+			files = snapshot(patterns())
+			start()
+		}
+	}
+}
+
+// magefilesChanged reports whether the magefiles changed since the binary at
+// exePath was compiled, using the hash of the magefiles in its name.
+func magefilesChanged(inv Invocation, exePath string) bool {
+	files, err := Magefiles(inv.Dir, inv.GOOS, inv.GOARCH, inv.UsesMagefiles())
+	if err != nil {
+		return true
+	}
+	name, err := ExeName(inv.GoCmd, inv.CacheDir, files)
+	return err != nil || name != exePath
+}
+
+// watchGlobs returns the globs of the files to watch for the targets in the
+// args: the magefiles, and the files matched by the mage:watch globs of the
+// targets, relative to the working directory.
+func watchGlobs(inv Invocation, errlog *log.Logger) []string {
+	dir, err := filepath.Abs(inv.Dir)
+	if err != nil {
+		dir = inv.Dir
+	}
+	globs := []string{filepath.Join(dir, "*.go")}
+
+	files, err := Magefiles(inv.Dir, inv.GOOS, inv.GOARCH, inv.UsesMagefiles())
+	if err != nil {
+		errlog.Println("Error determining list of magefiles:", err)
+		return globs
+	}
+	fnames := make([]string, 0, len(files))
+	for i := range files {
+		fnames = append(fnames, filepath.Base(files[i]))
+	}
+	info, err := parse.PrimaryPackage(inv.GoCmd, inv.Dir, fnames, inv.Multiline)
+	if err != nil {
+		errlog.Println("Error parsing magefiles:", err)
+		return globs
+	}
+	targets := map[string]*parse.Function{}
+	for _, f := range info.Funcs {
+		targets[strings.ToLower(f.TargetName())] = f
+	}
+	for _, imp := range info.Imports {
+		for _, f := range imp.Info.Funcs {
+			targets[strings.ToLower(f.TargetName())] = f
+		}
+	}
+	for alias, f := range info.Aliases {
+		targets[strings.ToLower(alias)] = f
+	}
+	args := inv.Args
+	if len(args) == 0 && info.DefaultFunc != nil {
+		args = []string{info.DefaultFunc.TargetName()}
+	}
+	for _, arg := range args {
+		f, ok := targets[strings.ToLower(arg)]
+		if !ok {
+			continue
+		}
+		for _, g := range f.Watch {
+			if !filepath.IsAbs(g) {
+				g = filepath.Join(inv.WorkDir, g)
+			}
+			if abs, err := filepath.Abs(g); err == nil {
+				g = abs
+			}
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+// fileState is what is compared to tell whether a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchSnapshot holds the state of the files matched by each watched glob.
+type watchSnapshot map[string]map[string]fileState
+
+// snapshot returns the state of the files matched by the globs. Directories
+// are walked, leaving out hidden directories such as .git.
+func snapshot(globs []string) watchSnapshot {
+	s := watchSnapshot{}
+	for _, g := range globs {
+		if _, ok := s[g]; ok {
+			continue
+		}
+		files := map[string]fileState{}
+		matches, _ := filepath.Glob(g)
+		for _, m := range matches {
+			_ = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				if d.IsDir() {
+					if path != m && strings.HasPrefix(d.Name(), ".") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+				}
+				return nil
+			})
+		}
+		s[g] = files
+	}
+	return s
+}
+
+// changed reports whether any file matched by a glob of s was changed, added
+// or removed in now. Globs that are new in now, such as sources the targets
+// just recorded, aren't changes.
+func (s watchSnapshot) changed(now watchSnapshot) bool {
+	for g, files := range now {
+		before, ok := s[g]
+		if !ok {
+			continue
+		}
+		if len(before) != len(files) {
+			return true
+		}
+		for path, state := range files {
+			if b, ok := before[path]; !ok || !b.modTime.Equal(state.modTime) || b.size != state.size {
+				return true
+			}
+		}
+	}
+	return false
+}
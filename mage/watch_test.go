@@ -0,0 +1,194 @@
+package mage
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startWatching compiles the magefiles in dir and runs the given targets in
+// work until the returned function is called, which returns the exit code.
+func startWatching(t *testing.T, dir, work string, args ...string) (stop func() int) {
+	t.Helper()
+	interval, debounce := watchInterval, watchDebounce
+	watchInterval, watchDebounce = 20*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { watchInterval, watchDebounce = interval, debounce })
+
+	inv := Invocation{
+		Dir:     dir,
+		WorkDir: work,
+		Stdout:  io.Discard,
+		Stderr:  io.Discard,
+		Args:    args,
+	}
+	errlog := log.New(io.Discard, "", 0)
+	exePath, code, ok := prepare(&inv, errlog)
+	if !ok {
+		t.Fatalf("expected to compile the magefiles, but got exit code %v", code)
+	}
+	if magefilesChanged(inv, exePath) {
+		t.Fatal("expected the magefiles to be unchanged since they were compiled")
+	}
+	stopCh := make(chan os.Signal, 1)
+	result := make(chan int, 1)
+	go func() { result <- runWatching(inv, exePath, errlog, stopCh) }()
+	return func() int {
+		stopCh <- os.Interrupt
+		return <-result
+	}
+}
+
+// waitForRuns waits for the targets to have recorded the expected runs.
+func waitForRuns(t *testing.T, work, expected string) {
+	t.Helper()
+	var actual []byte
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		actual, _ = os.ReadFile(filepath.Join(work, "runs.txt"))
+		if string(actual) == expected {
+			return
+		}
+	}
+	t.Fatalf("expected runs:\n%s\ngot:\n%s", expected, actual)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	work := t.TempDir()
+	writeFile(t, filepath.Join(work, "watched", "a.txt"), "a")
+	writeFile(t, filepath.Join(work, "src.txt"), "source")
+	writeFile(t, filepath.Join(work, "unwatched.txt"), "unwatched")
+
+	stop := startWatching(t, "./testdata/watch", work, "build", "copy")
+	runs := "build\ncopy\n"
+	waitForRuns(t, work, runs)
+
+	// a file matched by the mage:watch glob of build
+	writeFile(t, filepath.Join(work, "watched", "b.txt"), "b")
+	runs += "build\ncopy\n"
+	waitForRuns(t, work, runs)
+
+	// a source passed to target.Path by copy
+	writeFile(t, filepath.Join(work, "src.txt"), "changed source")
+	runs += "build\ncopy\n"
+	waitForRuns(t, work, runs)
+
+	writeFile(t, filepath.Join(work, "unwatched.txt"), "changed")
+	time.Sleep(10 * watchInterval)
+	waitForRuns(t, work, runs)
+
+	if code := stop(); code != 0 {
+		t.Fatalf("expected exit code 0, but got %v", code)
+	}
+}
+
+func TestWatchMagefilesChanged(t *testing.T) {
+	// the magefiles are copied to a directory in the module, so they can be
+	// compiled.
+	dir, err := os.MkdirTemp("testdata", "watchedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	b, err := os.ReadFile(filepath.Join("testdata", "watch", "magefile.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magefile := filepath.Join(dir, "magefile.go")
+	writeFile(t, magefile, string(b))
+	edit := func(content string) {
+		// make sure the edit changes the modification time.
+		time.Sleep(10 * time.Millisecond)
+		writeFile(t, magefile, content)
+	}
+
+	work := t.TempDir()
+	stop := startWatching(t, dir, work, "build")
+	runs := "build\n"
+	waitForRuns(t, work, runs)
+
+	edit(strings.Replace(string(b), `record("build")`, `record("rebuilt")`, 1))
+	runs += "rebuilt\n"
+	waitForRuns(t, work, runs)
+
+	// the targets aren't run while the magefiles don't compile, and are run
+	// again once they are fixed.
+	edit(string(b) + "\nfunc Broken() {")
+	time.Sleep(20 * watchInterval)
+	waitForRuns(t, work, runs)
+	edit(strings.Replace(string(b), `record("build")`, `record("fixed")`, 1))
+	runs += "fixed\n"
+	waitForRuns(t, work, runs)
+
+	if code := stop(); code != 0 {
+		t.Fatalf("expected exit code 0, but got %v", code)
+	}
+}
+
+func TestWatchForgetsSources(t *testing.T) {
+	work := t.TempDir()
+	writeFile(t, filepath.Join(work, "a.txt"), "a")
+	writeFile(t, filepath.Join(work, "b.txt"), "b")
+	writeFile(t, filepath.Join(work, "use.txt"), "a.txt")
+
+	stop := startWatching(t, "./testdata/watch", work, "switch")
+	runs := "switch\n"
+	waitForRuns(t, work, runs)
+
+	writeFile(t, filepath.Join(work, "use.txt"), "b.txt")
+	runs += "switch\n"
+	waitForRuns(t, work, runs)
+
+	// a.txt was only a source of the first run.
+	writeFile(t, filepath.Join(work, "a.txt"), "changed")
+	time.Sleep(10 * watchInterval)
+	waitForRuns(t, work, runs)
+
+	writeFile(t, filepath.Join(work, "b.txt"), "changed")
+	runs += "switch\n"
+	waitForRuns(t, work, runs)
+
+	if code := stop(); code != 0 {
+		t.Fatalf("expected exit code 0, but got %v", code)
+	}
+}
+
+func TestWatchCancelsRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent on windows")
+	}
+	work := t.TempDir()
+	writeFile(t, filepath.Join(work, "trigger.txt"), "1")
+
+	stop := startWatching(t, "./testdata/watch", work, "wait")
+	waitForRuns(t, work, "start\n")
+
+	writeFile(t, filepath.Join(work, "trigger.txt"), "12")
+	waitForRuns(t, work, "start\ncancelled\nstart\n")
+
+	writeFile(t, filepath.Join(work, "finish.txt"), "")
+	waitForRuns(t, work, "start\ncancelled\nstart\nfinished\n")
+	if code := stop(); code != 0 {
+		t.Fatalf("expected exit code 0, but got %v", code)
+	}
+}
+
+func TestWatchWithCommand(t *testing.T) {
+	_, _, err := Parse(io.Discard, io.Discard, []string{"-watch", "-l"})
+	if err == nil || err.Error() != "-watch can only be used when running targets" {
+		t.Fatalf("expected an error about -watch, but got %v", err)
+	}
+}
@@ -15,8 +15,9 @@ import (
 )
 
 // Install runs "go install" for mage. This generates the version info the binary.
+//
+// Deprecated: Just use go install now.
 func Install() error {
-	fmt.Println("`mage install` is deprecated. Just use `go install` now.")
 	name := "mage"
 	if runtime.GOOS == "windows" {
 		name += ".exe"
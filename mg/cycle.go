@@ -0,0 +1,74 @@
+package mg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// waitFor records that the running dependency parent is waiting on child. If
+// child is itself waiting, directly or through other dependencies, on parent,
+// waiting would deadlock, so the dependency cycle is returned instead, starting
+// and ending with parent.
+func (o *onceMap) waitFor(parent, child onceKey) []onceKey {
+	defer o.mu.Unlock()
+	o.mu.Lock()
+
+	if _, ok := o.m[parent]; !ok {
+		// the parent isn't a dependency we know of, so it can't be part of
+		// a cycle.
+		return nil
+	}
+	if path := o.waitPath(child, parent, map[onceKey]bool{}); path != nil {
+		return append([]onceKey{parent}, path...)
+	}
+	if o.waits[parent] == nil {
+		o.waits[parent] = map[onceKey]int{}
+	}
+	o.waits[parent][child]++
+	return nil
+}
+
+// doneWaiting records that parent is no longer waiting on child.
+func (o *onceMap) doneWaiting(parent, child onceKey) {
+	defer o.mu.Unlock()
+	o.mu.Lock()
+
+	children, ok := o.waits[parent]
+	if !ok {
+		return
+	}
+	if children[child]--; children[child] <= 0 {
+		delete(children, child)
+	}
+	if len(children) == 0 {
+		delete(o.waits, parent)
+	}
+}
+
+// waitPath returns the chain of dependencies from waiting on to, or nil if
+// from isn't waiting on to. It must be called with the mutex held.
+func (o *onceMap) waitPath(from, to onceKey, seen map[onceKey]bool) []onceKey {
+	if from == to {
+		return []onceKey{to}
+	}
+	if seen[from] {
+		return nil
+	}
+	seen[from] = true
+	for next := range o.waits[from] {
+		if path := o.waitPath(next, to, seen); path != nil {
+			return append([]onceKey{from}, path...)
+		}
+	}
+	return nil
+}
+
+// cycleError returns the error for a dependency cycle, such as
+// "dependency cycle: Build -> Generate -> Build".
+func cycleError(cycle []onceKey) error {
+	names := make([]string, len(cycle))
+	for i, k := range cycle {
+		names[i] = DepNode(k).String()
+	}
+	return fmt.Errorf("dependency cycle: %s", strings.Join(names, " -> "))
+}
@@ -0,0 +1,66 @@
+package mg
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func cycleA() { Deps(cycleB) }
+
+func cycleB() { Deps(cycleC) }
+
+func cycleC() { Deps(cycleA) }
+
+func cycleSelf() { Deps(cycleSelf) }
+
+// recoverDeps runs Deps with fns and returns what it panicked with, failing
+// the test if it doesn't return in time.
+func recoverDeps(t *testing.T, fns ...interface{}) interface{} {
+	t.Helper()
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		Deps(fns...)
+	}()
+	select {
+	case v := <-done:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("dependencies deadlocked")
+		return nil
+	}
+}
+
+func TestDepsCycle(t *testing.T) {
+	v := recoverDeps(t, cycleA)
+	if v == nil {
+		t.Fatal("expected panic, but didn't get one")
+	}
+	expected := "dependency cycle: github.com/magefile/mage/mg.cycleC -> github.com/magefile/mage/mg.cycleA -> github.com/magefile/mage/mg.cycleB -> github.com/magefile/mage/mg.cycleC"
+	if actual := fmt.Sprint(v); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+	if code := ExitStatus(v.(error)); code != 1 {
+		t.Fatalf("expected exit status 1, but got %v", code)
+	}
+}
+
+func TestDepsCycleSelf(t *testing.T) {
+	v := recoverDeps(t, cycleSelf)
+	expected := "dependency cycle: github.com/magefile/mage/mg.cycleSelf -> github.com/magefile/mage/mg.cycleSelf"
+	if actual := fmt.Sprint(v); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestDepsSharedNotCycle(t *testing.T) {
+	leaf := func() {}
+	left := func() { Deps(leaf) }
+	right := func() { Deps(leaf, left) }
+	if v := recoverDeps(t, left, right); v != nil {
+		t.Fatalf("unexpected panic: %v", v)
+	}
+}
@@ -2,6 +2,7 @@ package mg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 var logger = log.New(os.Stderr, "", 0)
@@ -16,6 +18,11 @@ var logger = log.New(os.Stderr, "", 0)
 type onceMap struct {
 	mu *sync.Mutex
 	m  map[onceKey]*onceFun
+
+	// waits holds, for each running dependency waiting on its own
+	// dependencies, how many times it waits on each of them. It is used to
+	// detect dependency cycles.
+	waits map[onceKey]map[onceKey]int
 }
 
 type onceKey struct {
@@ -38,15 +45,51 @@ func (o *onceMap) LoadOrStore(f Fn) *onceFun {
 	one := &onceFun{
 		once:        &sync.Once{},
 		fn:          f,
+		key:         key,
 		displayName: displayName(f.Name()),
 	}
 	o.m[key] = one
 	return one
 }
 
+// lookupName returns the key of the dependency with the given name. If
+// several dependencies share the name (such as mg.F with different args), only
+// a single one that is currently running is returned. Running reports whether
+// any dependency with the name is currently running.
+func (o *onceMap) lookupName(name string) (key onceKey, ok, running bool) {
+	defer o.mu.Unlock()
+	o.mu.Lock()
+
+	var found, run onceKey
+	n, r := 0, 0
+	for k, v := range o.m {
+		if k.Name != name {
+			continue
+		}
+		found = k
+		n++
+		if v.running {
+			run = k
+			r++
+		}
+	}
+	if n == 1 {
+		return found, true, r == 1
+	}
+	return run, r == 1, r > 0
+}
+
+// setRunning records whether the function is currently running.
+func (o *onceMap) setRunning(f *onceFun, running bool) {
+	defer o.mu.Unlock()
+	o.mu.Lock()
+	f.running = running
+}
+
 var onces = &onceMap{
-	mu: &sync.Mutex{},
-	m:  map[onceKey]*onceFun{},
+	mu:    &sync.Mutex{},
+	m:     map[onceKey]*onceFun{},
+	waits: map[onceKey]map[onceKey]int{},
 }
 
 // SerialDeps is like Deps except it runs each dependency serially, instead of
@@ -55,8 +98,9 @@ var onces = &onceMap{
 func SerialDeps(fns ...interface{}) {
 	funcs := checkFns(fns)
 	ctx := context.Background()
+	callers := callerNames()
 	for i := range fns {
-		runDeps(ctx, funcs[i:i+1])
+		runDeps(ctx, callers, funcs[i:i+1], false)
 	}
 }
 
@@ -65,8 +109,9 @@ func SerialDeps(fns ...interface{}) {
 // dependencies that shouldn't be run at the same time.
 func SerialCtxDeps(ctx context.Context, fns ...interface{}) {
 	funcs := checkFns(fns)
+	callers := callerNames()
 	for i := range fns {
-		runDeps(ctx, funcs[i:i+1])
+		runDeps(ctx, callers, funcs[i:i+1], false)
 	}
 }
 
@@ -88,37 +133,97 @@ func SerialCtxDeps(ctx context.Context, fns ...interface{}) {
 // prototype allows for it.
 func CtxDeps(ctx context.Context, fns ...interface{}) {
 	funcs := checkFns(fns)
-	runDeps(ctx, funcs)
+	runDeps(ctx, callerNames(), funcs, FailFast())
 }
 
-// runDeps assumes you've already called checkFns.
-func runDeps(ctx context.Context, fns []Fn) {
+// CtxDepsFailFast is like CtxDeps except that as soon as one of the
+// dependencies fails, the context passed to the others is cancelled, so that
+// they can stop early. The errors of all failed dependencies are still
+// reported.
+func CtxDepsFailFast(ctx context.Context, fns ...interface{}) {
+	funcs := checkFns(fns)
+	runDeps(ctx, callerNames(), funcs, true)
+}
+
+// DepsFailFast is like Deps except that as soon as one of the dependencies
+// fails, the context passed to the others is cancelled, so that they can stop
+// early. The errors of all failed dependencies are still reported.
+func DepsFailFast(fns ...interface{}) {
+	funcs := checkFns(fns)
+	runDeps(context.Background(), callerNames(), funcs, true)
+}
+
+// runDeps assumes you've already called checkFns. Callers are the names of the
+// functions on the stack of the call into mg, used to find the parent of fns
+// in the dependency graph. If failFast is true, the context passed to fns is
+// cancelled as soon as one of them fails.
+func runDeps(ctx context.Context, callers []string, fns []Fn, failFast bool) {
+	parent, running := depParent(callers)
+	for _, f := range fns {
+		to := DepNode{Name: f.Name(), ID: f.ID()}
+		if graph.addEdge(parent, to) {
+			recordEdge(parent, to)
+		}
+	}
+
+	// A dependency waiting on its own dependencies gives up its job slot so
+	// they can run, and takes it back once they are done.
+	defer yieldJob(ctx)()
+
 	mu := &sync.Mutex{}
 	var errs []string
 	var exit int
+	var cancelled bool
+
+	// When failing fast, the first failure cancels the context of the
+	// remaining dependencies. Their errors are still reported, but the exit
+	// code is left to the failures that caused the cancellation.
+	cancel := func() {}
+	if failFast {
+		var cancelCtx context.CancelFunc
+		ctx, cancelCtx = context.WithCancel(ctx)
+		defer cancelCtx()
+		cancel = func() {
+			cancelled = true
+			cancelCtx()
+		}
+	}
+	fail := func(err interface{}, code int) {
+		defer mu.Unlock()
+		mu.Lock()
+		if e, ok := err.(error); !ok || !cancelled || !errors.Is(e, context.Canceled) {
+			exit = changeExit(exit, code)
+		}
+		errs = append(errs, fmt.Sprint(err))
+		cancel()
+	}
+
 	wg := &sync.WaitGroup{}
 	for _, f := range fns {
 		fn := onces.LoadOrStore(f)
+		if running {
+			// waiting on a dependency that is waiting on the parent would
+			// deadlock, so fail instead.
+			if cycle := onces.waitFor(onceKey(parent), fn.key); cycle != nil {
+				fail(cycleError(cycle), 1)
+				continue
+			}
+			defer onces.doneWaiting(onceKey(parent), fn.key)
+		}
 		wg.Add(1)
 		go func() {
 			defer func() {
 				if v := recover(); v != nil {
-					mu.Lock()
 					if err, ok := v.(error); ok {
-						exit = changeExit(exit, ExitStatus(err))
+						fail(v, ExitStatus(err))
 					} else {
-						exit = changeExit(exit, 1)
+						fail(v, 1)
 					}
-					errs = append(errs, fmt.Sprint(v))
-					mu.Unlock()
 				}
 				wg.Done()
 			}()
-			if err := fn.run(ctx); err != nil {
-				mu.Lock()
-				errs = append(errs, fmt.Sprint(err))
-				exit = changeExit(exit, ExitStatus(err))
-				mu.Unlock()
+			if err := fn.run(ctx, parent); err != nil {
+				fail(err, ExitStatus(err))
 			}
 		}()
 	}
@@ -163,7 +268,8 @@ func checkFns(fns []interface{}) []Fn {
 // defining its own dependencies.  Functions must have the same signature as a
 // Mage target, i.e. optional context argument, optional error return.
 func Deps(fns ...interface{}) {
-	CtxDeps(context.Background(), fns...)
+	funcs := checkFns(fns)
+	runDeps(context.Background(), callerNames(), funcs, FailFast())
 }
 
 func changeExit(old, nw int) int {
@@ -181,6 +287,24 @@ func changeExit(old, nw int) int {
 	return 1
 }
 
+// callerNames returns the names of the functions on the stack of the caller of
+// the mg function which is calling callerNames, innermost first.
+func callerNames() []string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var names []string
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			names = append(names, frame.Function)
+		}
+		if !more {
+			return names
+		}
+	}
+}
+
 // funcName returns the unique name for the function.
 func funcName(i interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
@@ -197,19 +321,51 @@ func displayName(name string) string {
 type onceFun struct {
 	once *sync.Once
 	fn   Fn
+	key  onceKey
 	err  error
 
+	// running is guarded by the mutex of onces.
+	running bool
+
 	displayName string
 }
 
 // run will run the function exactly once and capture the error output. Further runs simply return
 // the same error output.
-func (o *onceFun) run(ctx context.Context) error {
+func (o *onceFun) run(ctx context.Context, parent DepNode) error {
+	start := time.Now()
+	cached, failed := true, true
+	defer func() {
+		recordTiming(o.key, parent, start, cached, failed, o.err)
+	}()
 	o.once.Do(func() {
+		cached = false
+		ctx, slot := acquireSlot(ctx)
+		defer slot.finish()
 		if Verbose() {
 			logger.Println("Running dependency:", displayName(o.fn.Name()))
 		}
-		o.err = o.fn.Run(ctx)
+		onces.setRunning(o, true)
+		defer onces.setRunning(o, false)
+		began := time.Now()
+		emit(o.event(DepStarted, parent))
+		defer func() {
+			if v := recover(); v != nil {
+				o.emitPanic(parent, began, v)
+				panic(v)
+			}
+		}()
+		o.err = runDependency(ctx, func(ctx context.Context) error {
+			return registeredHooks().Run(ctx, DepNode(o.key).String(), o.fn.Run)
+		})
+		o.emitDone(parent, began, o.err)
 	})
+	failed = o.err != nil
+	if cached {
+		e := o.event(DepCached, parent)
+		e.Err = o.err
+		e.ExitCode = ExitStatus(o.err)
+		emit(e)
+	}
 	return o.err
 }
@@ -283,3 +283,83 @@ func TestSerialCtxDeps(t *testing.T) {
 		t.Fatalf("expected serial execution f then g, got %s then %s", first, second)
 	}
 }
+
+func TestDepsFailFast(t *testing.T) {
+	f := func() error {
+		return Fatal(99, "ouch!")
+	}
+	g := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+	start := time.Now()
+	defer func() {
+		v := recover()
+		if v == nil {
+			t.Fatal("expected panic, but didn't get one")
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("expected g to be cancelled, but it took %v", d)
+		}
+		actual := fmt.Sprint(v)
+		if actual != "ouch!\ncontext canceled" {
+			t.Fatalf(`expected to get "ouch!" and "context canceled" but got %q`, actual)
+		}
+		// the cancellation doesn't change the exit code of the failure that
+		// caused it.
+		if code := ExitStatus(v.(error)); code != 99 {
+			t.Fatalf("Expected exit status 99, but got %v", code)
+		}
+	}()
+	DepsFailFast(f, g)
+}
+
+func TestCtxDepsFailFastGlobal(t *testing.T) {
+	t.Setenv("MAGEFILE_FAILFAST", "true")
+	f := func() error {
+		return errors.New("oops")
+	}
+	var cancelled int64
+	g := func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+			atomic.AddInt64(&cancelled, 1)
+		case <-time.After(5 * time.Second):
+		}
+	}
+	defer func() {
+		if v := recover(); v == nil {
+			t.Fatal("expected panic, but didn't get one")
+		}
+		if cancelled != 1 {
+			t.Fatal("expected g to be cancelled")
+		}
+	}()
+	CtxDeps(context.Background(), f, g)
+}
+
+func TestDepsNoFailFast(t *testing.T) {
+	f := func() error {
+		return errors.New("oops")
+	}
+	var finished int64
+	g := func(ctx context.Context) {
+		time.Sleep(50 * time.Millisecond)
+		if ctx.Err() == nil {
+			atomic.AddInt64(&finished, 1)
+		}
+	}
+	defer func() {
+		if v := recover(); v == nil {
+			t.Fatal("expected panic, but didn't get one")
+		}
+		if finished != 1 {
+			t.Fatal("expected g to run to completion")
+		}
+	}()
+	Deps(f, g)
+}
@@ -0,0 +1,153 @@
+package mg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+// The events sent to listeners while running dependencies.
+const (
+	// DepStarted is sent when a dependency starts running.
+	DepStarted EventType = iota
+	// DepFinished is sent when a dependency finished without error.
+	DepFinished
+	// DepCached is sent when a dependency is called after it already ran,
+	// or while it is running, so that it isn't run again.
+	DepCached
+	// DepFailed is sent when a dependency returned an error, or one of its
+	// own dependencies failed.
+	DepFailed
+	// DepPanicked is sent when a dependency panicked.
+	DepPanicked
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DepStarted:
+		return "started"
+	case DepFinished:
+		return "finished"
+	case DepCached:
+		return "cached"
+	case DepFailed:
+		return "failed"
+	case DepPanicked:
+		return "panicked"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes something that happened while running a dependency.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	Name   string        // fully qualified name of the function
+	ID     string        // ID of the mg.Fn, see Fn.ID
+	Args   []interface{} // args passed to F, if any
+	Parent DepNode       // function that declared the dependency
+
+	// Duration is how long the dependency ran, for DepFinished, DepFailed and
+	// DepPanicked.
+	Duration time.Duration
+	// Err is the error the dependency failed with, for DepFailed and
+	// DepPanicked, and for DepCached when the dependency had failed.
+	Err error
+	// ExitCode is the exit status of Err, see ExitStatus.
+	ExitCode int
+	// Panic is the value the dependency panicked with, for DepPanicked.
+	Panic interface{}
+}
+
+var (
+	listenersMu sync.Mutex
+	listeners   []func(Event)
+)
+
+// AddListener registers fn to be called with every Event of the dependencies
+// run with Deps and friends. Listeners are called synchronously from the
+// goroutine running the dependency, so they must be safe for concurrent use
+// and should return quickly.
+func AddListener(fn func(Event)) {
+	defer listenersMu.Unlock()
+	listenersMu.Lock()
+	listeners = append(listeners, fn)
+}
+
+// emit sends e to all listeners.
+func emit(e Event) {
+	listenersMu.Lock()
+	ls := listeners
+	listenersMu.Unlock()
+	for _, l := range ls {
+		l(e)
+	}
+}
+
+// event returns an Event of the given type for the dependency.
+func (o *onceFun) event(t EventType, parent DepNode) Event {
+	return Event{
+		Type:   t,
+		Time:   time.Now(),
+		Name:   o.key.Name,
+		ID:     o.key.ID,
+		Args:   fnArgs(o.fn),
+		Parent: parent,
+	}
+}
+
+// emitDone sends the event for a dependency that started at start and
+// returned err.
+func (o *onceFun) emitDone(parent DepNode, start time.Time, err error) {
+	e := o.event(DepFinished, parent)
+	e.Duration = e.Time.Sub(start)
+	if err != nil {
+		e.Type = DepFailed
+		e.Err = err
+		e.ExitCode = ExitStatus(err)
+	}
+	emit(e)
+}
+
+// emitPanic sends the event for a dependency that started at start and
+// panicked with v. The dependencies of a dependency report their failures by
+// panicking with the error from Fatal, so those are reported as failures.
+func (o *onceFun) emitPanic(parent DepNode, start time.Time, v interface{}) {
+	err, ok := v.(error)
+	var fatal fatalError
+	if ok && errors.As(err, &fatal) {
+		o.emitDone(parent, start, err)
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%v", v)
+	}
+	e := o.event(DepPanicked, parent)
+	e.Duration = e.Time.Sub(start)
+	e.Panic = v
+	e.Err = err
+	e.ExitCode = ExitStatus(err)
+	emit(e)
+}
+
+// fnArgs returns the args passed to F for f, if any.
+func fnArgs(f Fn) []interface{} {
+	switch f := f.(type) {
+	case fn:
+		return f.args
+	case limitFn:
+		return fnArgs(f.Fn)
+	case retryFn:
+		return fnArgs(f.Fn)
+	case timeoutFn:
+		return fnArgs(f.Fn)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package mg
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordEvents records the events of functions whose name contains the given
+// test name.
+func recordEvents(name string) func() []Event {
+	mu := &sync.Mutex{}
+	var events []Event
+	AddListener(func(e Event) {
+		if !strings.Contains(e.Name, name) {
+			return
+		}
+		defer mu.Unlock()
+		mu.Lock()
+		events = append(events, e)
+	})
+	return func() []Event {
+		defer mu.Unlock()
+		mu.Lock()
+		return append([]Event(nil), events...)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	events := recordEvents("TestEvents")
+	compile := func(what string, n int) {}
+	SerialDeps(F(compile, "server", 2), F(compile, "server", 2))
+
+	got := events()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, but got %v", got)
+	}
+	for i, typ := range []EventType{DepStarted, DepFinished, DepCached} {
+		if got[i].Type != typ {
+			t.Errorf("expected event %d to be %v, but got %v", i, typ, got[i].Type)
+		}
+	}
+	e := got[1]
+	if e.Name != funcName(compile) {
+		t.Errorf("expected name %q, but got %q", funcName(compile), e.Name)
+	}
+	if e.ID != `["server",2]` {
+		t.Errorf("expected ID %q, but got %q", `["server",2]`, e.ID)
+	}
+	if !reflect.DeepEqual(e.Args, []interface{}{"server", 2}) {
+		t.Errorf("expected args [server 2], but got %v", e.Args)
+	}
+	if !strings.HasSuffix(e.Parent.Name, "TestEvents") {
+		t.Errorf("expected the test to be the parent, but got %q", e.Parent.Name)
+	}
+}
+
+func TestEventsFailed(t *testing.T) {
+	events := recordEvents("TestEventsFailed")
+	leaf := func() error { return Fatal(4, "oops") }
+	mid := func() { Deps(leaf) }
+	boom := func() { panic("boom") }
+	func() {
+		defer func() { _ = recover() }()
+		SerialDeps(mid)
+	}()
+	func() {
+		defer func() { _ = recover() }()
+		SerialDeps(boom)
+	}()
+
+	byName := map[string]Event{}
+	for _, e := range events() {
+		if e.Type != DepStarted {
+			byName[e.Name] = e
+		}
+	}
+	for _, f := range []interface{}{leaf, mid} {
+		e := byName[funcName(f)]
+		if e.Type != DepFailed || e.ExitCode != 4 || e.Err == nil || e.Err.Error() != "oops" {
+			t.Errorf("expected %s to fail with exit code 4, but got %+v", funcName(f), e)
+		}
+	}
+	e := byName[funcName(boom)]
+	if e.Type != DepPanicked || e.Panic != "boom" || e.ExitCode != 1 {
+		t.Errorf("expected boom to panic, but got %+v", e)
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	if s := DepCached.String(); s != "cached" {
+		t.Fatalf("expected cached, but got %q", s)
+	}
+	if s := EventType(42).String(); s != "EventType(42)" {
+		t.Fatalf("expected EventType(42), but got %q", s)
+	}
+}
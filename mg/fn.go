@@ -2,6 +2,7 @@ package mg
 
 import (
 	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,8 +30,9 @@ type Fn interface {
 // F takes a function that is compatible as a mage target, and any args that need to be passed to
 // it, and wraps it in an mg.Fn that mg.Deps can run. Args must be passed in the same order as they
 // are declared by the function. Note that you do not need to and should not pass a context.Context
-// to F, even if the target takes a context. Compatible args are int, bool, string, and
-// time.Duration.
+// to F, even if the target takes a context. Compatible args are int, bool, string, float64,
+// time.Duration, slices of those, types that implement encoding.TextUnmarshaler, and options
+// structs.
 func F(target interface{}, args ...interface{}) Fn {
 	hasContext, isNamespace, err := checkF(target, args)
 	if err != nil {
@@ -43,6 +45,7 @@ func F(target interface{}, args ...interface{}) Fn {
 	return fn{
 		name: funcName(target),
 		id:   string(id),
+		args: args,
 		f: func(ctx context.Context) error {
 			v := reflect.ValueOf(target)
 			count := len(args)
@@ -85,6 +88,7 @@ func F(target interface{}, args ...interface{}) Fn {
 type fn struct {
 	name string
 	id   string
+	args []interface{}
 	f    func(ctx context.Context) error
 }
 
@@ -161,7 +165,7 @@ func checkF(target interface{}, args []interface{}) (hasContext, isNamespace boo
 			// For the variadic argument, use the slice element type.
 			argT = argT.Elem()
 		}
-		if !argTypes[argT] {
+		if !argTypes[argT] && !isTextUnmarshaler(argT) && !isOptionsStruct(argT) {
 			return false, false, fmt.Errorf("argument %d (%s), is not a supported argument type", x, argT)
 		}
 		passedT := reflect.TypeOf(arg)
@@ -175,22 +179,65 @@ func checkF(target interface{}, args []interface{}) (hasContext, isNamespace boo
 	return hasContext, isNamespace, nil
 }
 
+// isTextUnmarshaler reports whether t implements encoding.TextUnmarshaler with
+// a pointer receiver, as arguments parsed from the command line must, since
+// with a value receiver the parsed value would be lost.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType) && !t.Implements(textUnmarshalerType)
+}
+
+// isOptionsStruct reports whether t is a struct that can be an options struct
+// of a target: its exported fields are of supported argument types, it has at
+// least one, and none are embedded.
+func isOptionsStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	exported := 0
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			return false
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if !argTypes[f.Type] && !isTextUnmarshaler(f.Type) {
+			return false
+		}
+		exported++
+	}
+	return exported > 0
+}
+
 // Here we define the types that are supported as arguments/returns.
 var (
 	ctxType   = reflect.TypeOf(func(context.Context) {}).In(0)
 	errType   = reflect.TypeOf(func() error { return nil }).Out(0)
 	emptyType = reflect.TypeOf(struct{}{})
 
-	intType    = reflect.TypeOf(int(0))
-	stringType = reflect.TypeOf(string(""))
-	boolType   = reflect.TypeOf(bool(false))
-	durType    = reflect.TypeOf(time.Second)
+	intType     = reflect.TypeOf(int(0))
+	stringType  = reflect.TypeOf(string(""))
+	boolType    = reflect.TypeOf(bool(false))
+	durType     = reflect.TypeOf(time.Second)
+	float64Type = reflect.TypeOf(float64(0))
+
+	// types implementing encoding.TextUnmarshaler are supported as well, and
+	// so are options structs, see isOptionsStruct.
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 
 	// don't put ctx in here, this is for non-context types.
 	argTypes = map[reflect.Type]bool{
-		intType:    true,
-		boolType:   true,
-		stringType: true,
-		durType:    true,
+		intType:     true,
+		boolType:    true,
+		stringType:  true,
+		durType:     true,
+		float64Type: true,
+
+		reflect.SliceOf(intType):     true,
+		reflect.SliceOf(boolType):    true,
+		reflect.SliceOf(stringType):  true,
+		reflect.SliceOf(durType):     true,
+		reflect.SliceOf(float64Type): true,
 	}
 )
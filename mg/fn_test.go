@@ -175,6 +175,105 @@ func TestF(t *testing.T) {
 	}
 }
 
+func TestFFloatAndSlices(t *testing.T) {
+	var (
+		fOut  float64
+		ssOut []string
+		isOut []int
+	)
+	f := func(ff float64, ss []string, is []int) {
+		fOut = ff
+		ssOut = ss
+		isOut = is
+	}
+	fn := F(f, 2.5, []string{"a", "b"}, []int{1, 2})
+	if fn.ID() != `[2.5,["a","b"],[1,2]]` {
+		t.Errorf("unexpected ID %s", fn.ID())
+	}
+	if err := fn.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fOut != 2.5 {
+		t.Error(fOut)
+	}
+	if !reflect.DeepEqual(ssOut, []string{"a", "b"}) {
+		t.Error(ssOut)
+	}
+	if !reflect.DeepEqual(isOut, []int{1, 2}) {
+		t.Error(isOut)
+	}
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	*l = level(len(text))
+	return nil
+}
+
+func TestFTextUnmarshaler(t *testing.T) {
+	var out level
+	fn := F(func(l level) { out = l }, level(3))
+	if err := fn.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out != 3 {
+		t.Error(out)
+	}
+}
+
+// valueLevel has an UnmarshalText method with a value receiver, which can't set
+// the value.
+type valueLevel int
+
+func (l valueLevel) UnmarshalText(text []byte) error {
+	return nil
+}
+
+func TestFTextUnmarshalerValueReceiver(t *testing.T) {
+	_, _, err := checkF(func(l valueLevel) {}, []interface{}{valueLevel(3)})
+	if err == nil {
+		t.Fatal("expected a type with a value receiver UnmarshalText to be invalid")
+	}
+}
+
+type deployOptions struct {
+	Region   string
+	Replicas int
+}
+
+func TestFOptionsStruct(t *testing.T) {
+	var out deployOptions
+	fn := F(func(env string, opts deployOptions) { out = opts }, "prod", deployOptions{Region: "eu", Replicas: 3})
+	if err := fn.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out != (deployOptions{Region: "eu", Replicas: 3}) {
+		t.Error(out)
+	}
+	if want := `["prod",{"Region":"eu","Replicas":3}]`; fn.ID() != want {
+		t.Errorf("expected ID %s, got %s", want, fn.ID())
+	}
+}
+
+func TestFInvalidOptionsStruct(t *testing.T) {
+	type nested struct {
+		Opts deployOptions
+	}
+	type private struct {
+		region string
+	}
+	type channel struct {
+		C chan int
+	}
+	for _, arg := range []interface{}{channel{}, nested{}, private{}, struct{ deployOptions }{}} {
+		fn := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{reflect.TypeOf(arg)}, nil, false), nil).Interface()
+		if _, _, err := checkF(fn, []interface{}{arg}); err == nil {
+			t.Errorf("expected a struct of type %T to be an invalid argument", arg)
+		}
+	}
+}
+
 func TestFTwice(t *testing.T) {
 	var called int64
 	f := func(int) {
@@ -0,0 +1,239 @@
+package mg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/magefile/mage/internal"
+)
+
+// DepNode is a function in the dependency graph.
+type DepNode struct {
+	// Name is the fully qualified name of the function, as returned by Fn.Name.
+	Name string `json:"name"`
+	// ID is the uniqueness qualifier of the function, as returned by Fn.ID.
+	// It is empty for functions that were not run as a dependency, such as
+	// the top level target.
+	ID string `json:"id,omitempty"`
+}
+
+// String returns a human readable name for the node, including any arguments
+// passed to it with mg.F.
+func (n DepNode) String() string {
+	name := displayName(n.Name)
+	switch n.ID {
+	case "", "null", "[]":
+		return name
+	}
+	if strings.HasPrefix(n.ID, "[") && strings.HasSuffix(n.ID, "]") {
+		return name + "(" + n.ID[1:len(n.ID)-1] + ")"
+	}
+	return name + "(" + n.ID + ")"
+}
+
+// DepEdge records that From declared To as a dependency.
+type DepEdge struct {
+	From DepNode `json:"from"`
+	To   DepNode `json:"to"`
+}
+
+// DepGraph is a snapshot of the functions run with Deps, CtxDeps, SerialDeps
+// and SerialCtxDeps, and the functions that declared them as dependencies.
+// Nodes and edges are listed in the order they were first seen.
+type DepGraph struct {
+	Nodes []DepNode `json:"nodes"`
+	Edges []DepEdge `json:"edges"`
+}
+
+// Graph returns the dependency graph recorded so far in this execution of
+// mage.
+func Graph() DepGraph {
+	return graph.snapshot()
+}
+
+// WriteDOT writes the graph to w in the Graphviz DOT language.
+func (g DepGraph) WriteDOT(w io.Writer) error {
+	var buf strings.Builder
+	_, _ = buf.WriteString("digraph mage {\n")
+	for _, n := range g.Nodes {
+		_, _ = fmt.Fprintf(&buf, "\t%q;\n", n.String())
+	}
+	for _, e := range g.Edges {
+		_, _ = fmt.Fprintf(&buf, "\t%q -> %q;\n", e.From.String(), e.To.String())
+	}
+	_, _ = buf.WriteString("}\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// WriteJSON writes the graph to w as an indented JSON object.
+func (g DepGraph) WriteJSON(w io.Writer) error {
+	if g.Nodes == nil {
+		g.Nodes = []DepNode{}
+	}
+	if g.Edges == nil {
+		g.Edges = []DepEdge{}
+	}
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteFile writes the graph to the given file, as JSON if the file has a
+// .json extension, and in the DOT language otherwise.
+func (g DepGraph) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("can't create dependency graph file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = g.WriteJSON(f)
+	} else {
+		err = g.WriteDOT(f)
+	}
+	if err != nil {
+		return fmt.Errorf("can't write dependency graph: %w", err)
+	}
+	return f.Close()
+}
+
+type depGraph struct {
+	mu    sync.Mutex
+	nodes []DepNode
+	edges []DepEdge
+	seenN map[DepNode]bool
+	seenE map[DepEdge]bool
+}
+
+var graph = newDepGraph()
+
+func newDepGraph() *depGraph {
+	return &depGraph{
+		seenN: map[DepNode]bool{},
+		seenE: map[DepEdge]bool{},
+	}
+}
+
+func (g *depGraph) addNode(n DepNode) bool {
+	if g.seenN[n] {
+		return false
+	}
+	g.seenN[n] = true
+	g.nodes = append(g.nodes, n)
+	return true
+}
+
+// addEdge adds the edge from the function that declared a dependency to the
+// dependency, or just the dependency if from is empty, and reports whether
+// the graph changed.
+func (g *depGraph) addEdge(from, to DepNode) bool {
+	defer g.mu.Unlock()
+	g.mu.Lock()
+
+	added := false
+	if from.Name != "" {
+		added = g.addNode(from)
+	}
+	if g.addNode(to) {
+		added = true
+	}
+	if from.Name == "" {
+		return added
+	}
+	e := DepEdge{From: from, To: to}
+	if !g.seenE[e] {
+		g.seenE[e] = true
+		g.edges = append(g.edges, e)
+		added = true
+	}
+	return added
+}
+
+func (g *depGraph) snapshot() DepGraph {
+	defer g.mu.Unlock()
+	g.mu.Lock()
+
+	return DepGraph{
+		Nodes: append([]DepNode(nil), g.nodes...),
+		Edges: append([]DepEdge(nil), g.edges...),
+	}
+}
+
+// depParent returns the node for the function that declared dependencies,
+// given the names of the functions on the stack of the call into mg, and
+// whether it is a dependency that is currently running. If the caller is
+// itself being run as a dependency, or is a helper called by one, that
+// dependency is the parent, otherwise the caller is a top level target (or a
+// function called by one).
+func depParent(callers []string) (DepNode, bool) {
+	for _, name := range callers {
+		key, ok, running := onces.lookupName(name)
+		if ok {
+			return DepNode(key), running
+		}
+		if running {
+			return DepNode{Name: name}, true
+		}
+	}
+	if len(callers) == 0 {
+		return DepNode{}, false
+	}
+	return DepNode{Name: callers[0]}, false
+}
+
+// recordEdge appends an edge that was added to the graph to the file mage
+// asked for with MAGEFILE_GRAPH_FILE, if any. Only new edges are recorded, so
+// mage can put the graph together once the compiled magefile exits.
+func recordEdge(from, to DepNode) {
+	path := os.Getenv(GraphFileEnv)
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(DepEdge{From: from, To: to})
+	if err == nil {
+		err = internal.AppendFile(path, append(b, '\n'))
+	}
+	if err != nil {
+		logger.Println("warning: can't record dependency graph:", err)
+	}
+}
+
+// ReadGraph reads the dependency graph from the edges recorded by a compiled
+// magefile run with MAGEFILE_GRAPH_FILE, one JSON object per line. An
+// incomplete last line, left by a magefile that was killed while writing it,
+// is ignored.
+func ReadGraph(r io.Reader) (DepGraph, error) {
+	g := newDepGraph()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return DepGraph{}, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var e DepEdge
+		if err := json.Unmarshal(line, &e); err != nil {
+			return DepGraph{}, fmt.Errorf("malformed dependency graph record %q: %w", line, err)
+		}
+		g.addEdge(e.From, e.To)
+	}
+	return g.snapshot(), nil
+}
@@ -0,0 +1,138 @@
+package mg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func graphRoot() {
+	Deps(graphMid, F(graphLeaf, "a"))
+}
+
+func graphMid() {
+	SerialDeps(F(graphLeaf, "a"), F(graphLeaf, "b"))
+}
+
+func graphLeaf(string) {}
+
+func hasEdge(g DepGraph, from, to string) bool {
+	for _, e := range g.Edges {
+		if e.From.String() == from && e.To.String() == to {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGraph(t *testing.T) {
+	graphRoot()
+	g := Graph()
+
+	for _, e := range [][2]string{
+		{"github.com/magefile/mage/mg.graphRoot", "github.com/magefile/mage/mg.graphMid"},
+		{"github.com/magefile/mage/mg.graphRoot", `github.com/magefile/mage/mg.graphLeaf("a")`},
+		{"github.com/magefile/mage/mg.graphMid", `github.com/magefile/mage/mg.graphLeaf("a")`},
+		{"github.com/magefile/mage/mg.graphMid", `github.com/magefile/mage/mg.graphLeaf("b")`},
+	} {
+		if !hasEdge(g, e[0], e[1]) {
+			t.Errorf("expected edge %s -> %s in graph %v", e[0], e[1], g.Edges)
+		}
+	}
+	if hasEdge(g, "github.com/magefile/mage/mg.graphRoot", `github.com/magefile/mage/mg.graphLeaf("b")`) {
+		t.Error("unexpected edge from graphRoot to graphLeaf(b)")
+	}
+}
+
+func TestGraphAttributesFArgs(t *testing.T) {
+	leaf := func(string) {}
+	compile := func(what string) {
+		Deps(F(leaf, what+"-dep"))
+	}
+	Deps(F(compile, "server"))
+	Deps(F(compile, "client"))
+
+	g := Graph()
+	name := funcName(compile)
+	leafName := funcName(leaf)
+	from := DepNode{Name: name, ID: `["server"]`}.String()
+	to := DepNode{Name: leafName, ID: `["server-dep"]`}.String()
+	if !hasEdge(g, from, to) {
+		t.Errorf("expected edge %s -> %s in graph %v", from, to, g.Edges)
+	}
+	from = DepNode{Name: name, ID: `["client"]`}.String()
+	to = DepNode{Name: leafName, ID: `["client-dep"]`}.String()
+	if !hasEdge(g, from, to) {
+		t.Errorf("expected edge %s -> %s in graph %v", from, to, g.Edges)
+	}
+}
+
+func TestGraphWriteDOT(t *testing.T) {
+	a := DepNode{Name: "main.Build"}
+	b := DepNode{Name: "main.compile", ID: `["server",1]`}
+	g := DepGraph{
+		Nodes: []DepNode{a, b},
+		Edges: []DepEdge{{From: a, To: b}},
+	}
+	buf := &bytes.Buffer{}
+	if err := g.WriteDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "digraph mage {\n\t\"Build\";\n\t\"compile(\\\"server\\\",1)\";\n\t\"Build\" -> \"compile(\\\"server\\\",1)\";\n}\n"
+	if actual := buf.String(); actual != expected {
+		t.Fatalf("expected %q but got %q", expected, actual)
+	}
+}
+
+func TestGraphFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph")
+	t.Setenv(GraphFileEnv, path)
+
+	// use new functions, since edges already in the graph aren't recorded
+	// again.
+	leaf := func() {}
+	mid := func() { Deps(leaf) }
+	root := func() { Deps(mid, leaf) }
+	root()
+	root()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := ReadGraph(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := func(fn interface{}) string {
+		return DepNode{Name: funcName(fn)}.String()
+	}
+	rootName, midName, leafName := name(root), name(mid), name(leaf)
+	for _, e := range [][2]string{
+		{rootName, midName},
+		{rootName, leafName},
+		{midName, leafName},
+	} {
+		if !hasEdge(g, e[0], e[1]) {
+			t.Errorf("expected edge %s -> %s in graph %v", e[0], e[1], g.Edges)
+		}
+	}
+	if len(g.Edges) != 3 {
+		t.Errorf("expected 3 edges, but got %v", g.Edges)
+	}
+}
+
+func TestReadGraphPartialLine(t *testing.T) {
+	r := strings.NewReader(`{"from":{"name":"main.Build"},"to":{"name":"main.Generate"}}
+{"from":{"name":"main.Build"},"to":{"na`)
+	g, err := ReadGraph(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasEdge(g, "Build", "Generate") || len(g.Edges) != 1 {
+		t.Fatalf("expected only the edge from Build to Generate, but got %v", g.Edges)
+	}
+}
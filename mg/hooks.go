@@ -0,0 +1,90 @@
+package mg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Hooks are functions run around every top level target and every dependency.
+// To use them, declare a package level variable named Hooks in your magefile:
+//
+//	var Hooks = mg.Hooks{
+//		Before: func(ctx context.Context, name string) error {
+//			return sh.Run("docker", "network", "create", "build")
+//		},
+//		OnFailure: func(ctx context.Context, name string, err error) {
+//			notify(name + " failed: " + err.Error())
+//		},
+//	}
+//
+// Targets are named by their target name, such as "Build" or "Docker:Push",
+// and dependencies by their function name and args, such as `Compile("server")`.
+// Any of the hooks may be nil.
+type Hooks struct {
+	// Before is run before each target or dependency. If it returns an
+	// error, the target or dependency is not run and fails with that error.
+	Before func(ctx context.Context, name string) error
+
+	// After is run after each target or dependency, with the error it
+	// failed with, if any.
+	After func(ctx context.Context, name string, err error)
+
+	// OnFailure is run after each target or dependency that failed, before
+	// After.
+	OnFailure func(ctx context.Context, name string, err error)
+}
+
+var (
+	hooksMu sync.Mutex
+	hooks   Hooks
+)
+
+// Register makes h the hooks run around every dependency run with Deps and
+// friends. The compiled magefile calls it for the Hooks variable of the
+// magefile, so magefiles normally don't need to.
+func (h Hooks) Register() {
+	defer hooksMu.Unlock()
+	hooksMu.Lock()
+	hooks = h
+}
+
+func registeredHooks() Hooks {
+	defer hooksMu.Unlock()
+	hooksMu.Lock()
+	return hooks
+}
+
+// Run runs fn, with the given name, between the hooks. If fn panics, the
+// hooks are given the panic as an error, and the panic continues once they
+// are done.
+func (h Hooks) Run(ctx context.Context, name string, fn func(context.Context) error) (err error) {
+	if h.Before != nil {
+		if err := h.Before(ctx, name); err != nil {
+			h.done(ctx, name, err)
+			return err
+		}
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			perr, ok := v.(error)
+			if !ok {
+				perr = fmt.Errorf("%v", v)
+			}
+			h.done(ctx, name, perr)
+			panic(v)
+		}
+		h.done(ctx, name, err)
+	}()
+	return fn(ctx)
+}
+
+// done runs the hooks for a target or dependency that has finished.
+func (h Hooks) done(ctx context.Context, name string, err error) {
+	if err != nil && h.OnFailure != nil {
+		h.OnFailure(ctx, name, err)
+	}
+	if h.After != nil {
+		h.After(ctx, name, err)
+	}
+}
@@ -0,0 +1,85 @@
+package mg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHooksRun(t *testing.T) {
+	var calls []string
+	h := Hooks{
+		Before: func(_ context.Context, name string) error {
+			calls = append(calls, "before "+name)
+			return nil
+		},
+		After: func(_ context.Context, name string, err error) {
+			calls = append(calls, fmt.Sprint("after ", name, " ", err))
+		},
+		OnFailure: func(_ context.Context, name string, err error) {
+			calls = append(calls, fmt.Sprint("failed ", name, " ", err))
+		},
+	}
+	_ = h.Run(context.Background(), "ok", func(context.Context) error { return nil })
+	err := h.Run(context.Background(), "bad", func(context.Context) error { return errors.New("oops") })
+	if err == nil || err.Error() != "oops" {
+		t.Fatalf("expected error oops, but got %v", err)
+	}
+	expected := "before ok, after ok <nil>, before bad, failed bad oops, after bad oops"
+	if actual := strings.Join(calls, ", "); actual != expected {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestHooksBeforeFails(t *testing.T) {
+	var after error
+	h := Hooks{
+		Before: func(context.Context, string) error { return errors.New("no network") },
+		After:  func(_ context.Context, _ string, err error) { after = err },
+	}
+	ran := false
+	err := h.Run(context.Background(), "build", func(context.Context) error {
+		ran = true
+		return nil
+	})
+	if ran {
+		t.Fatal("expected the function not to run")
+	}
+	if err == nil || err != after {
+		t.Fatalf("expected the error of Before to be returned and given to After, but got %v and %v", err, after)
+	}
+}
+
+func TestHooksPanic(t *testing.T) {
+	var failed error
+	h := Hooks{
+		OnFailure: func(_ context.Context, _ string, err error) { failed = err },
+	}
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Fatalf("expected the panic to continue, but got %v", v)
+		}
+		if failed == nil || failed.Error() != "boom" {
+			t.Fatalf("expected OnFailure to get the panic, but got %v", failed)
+		}
+	}()
+	_ = h.Run(context.Background(), "build", func(context.Context) error { panic("boom") })
+}
+
+func TestHooksDeps(t *testing.T) {
+	var names []string
+	Hooks{
+		Before: func(_ context.Context, name string) error {
+			names = append(names, name)
+			return nil
+		},
+	}.Register()
+	defer Hooks{}.Register()
+
+	SerialDeps(F(func(string) {}, "a"))
+	if len(names) != 1 || !strings.HasSuffix(names[0], `("a")`) {
+		t.Fatalf("expected the hooks to run for the dependency, but got %q", names)
+	}
+}
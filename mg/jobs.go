@@ -0,0 +1,214 @@
+package mg
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// semaphore counts the functions that are running at the same time. The limit
+// is given when acquiring rather than when the semaphore is created, so that
+// the global limit follows the environment.
+type semaphore struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int
+}
+
+func newSemaphore() *semaphore {
+	s := &semaphore{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// acquire waits until fewer than limit functions hold the semaphore, and then
+// takes a slot. A limit of zero or less means there is no limit.
+func (s *semaphore) acquire(limit int) {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+
+	for limit > 0 && s.used >= limit {
+		s.cond.Wait()
+	}
+	s.used++
+}
+
+// release gives back a slot taken with acquire.
+func (s *semaphore) release() {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+
+	s.used--
+	s.cond.Broadcast()
+}
+
+// jobs limits how many dependencies run at the same time across all calls to
+// Deps and friends, see Jobs.
+var jobs = newSemaphore()
+
+// jobSlot is the slot of jobs held by a running dependency. The dependency
+// gives it up while it waits on its own dependencies, so they can run, and
+// takes it back once they are done.
+type jobSlot struct {
+	mu      sync.Mutex
+	held    bool // whether the dependency is still running
+	waiting int  // the calls to Deps the dependency is waiting on
+}
+
+// contextKey is the type of the keys of the values mg puts in contexts.
+type contextKey int
+
+// jobSlotKey is the context key of the jobSlot of the dependency the context
+// was given to.
+const jobSlotKey contextKey = 0
+
+// acquireSlot waits for a slot of jobs for a dependency that is about to run,
+// and returns ctx with the slot added. Without a limit on jobs, it returns ctx
+// as it is and a nil slot.
+func acquireSlot(ctx context.Context) (context.Context, *jobSlot) {
+	limit := Jobs()
+	if limit <= 0 {
+		return ctx, nil
+	}
+	jobs.acquire(limit)
+	s := &jobSlot{held: true}
+	return context.WithValue(ctx, jobSlotKey, s), s
+}
+
+// finish gives back the slot once the dependency is done.
+func (s *jobSlot) finish() {
+	if s == nil {
+		return
+	}
+	defer s.mu.Unlock()
+	s.mu.Lock()
+
+	if s.waiting == 0 {
+		jobs.release()
+	}
+	s.held = false
+}
+
+// yieldJob gives up the job held by the caller of Deps while it waits on the
+// dependencies it declared, and returns a function that takes it back. The
+// slot of a dependency is found in the context it was given. Dependencies
+// that call Deps without their context, on the goroutine mg runs them on,
+// still hold a job, which is given up without the bookkeeping of a slot.
+// Callers that aren't dependencies, such as top level targets, hold no job.
+func yieldJob(ctx context.Context) (resume func()) {
+	if Jobs() <= 0 {
+		return func() {}
+	}
+	if s, ok := ctx.Value(jobSlotKey).(*jobSlot); ok {
+		return s.yield()
+	}
+	if !inDependency() {
+		return func() {}
+	}
+	jobs.release()
+	return func() { jobs.acquire(Jobs()) }
+}
+
+// yield gives up the slot until resume is called, unless the dependency is
+// done. Several calls to Deps may wait at once, such as from goroutines the
+// dependency started; the slot is taken back once all of them are done.
+func (s *jobSlot) yield() (resume func()) {
+	s.mu.Lock()
+	if !s.held {
+		s.mu.Unlock()
+		return func() {}
+	}
+	if s.waiting == 0 {
+		jobs.release()
+	}
+	s.waiting++
+	s.mu.Unlock()
+
+	return func() {
+		defer s.mu.Unlock()
+		s.mu.Lock()
+
+		s.waiting--
+		if s.waiting == 0 && s.held {
+			jobs.acquire(Jobs())
+		}
+	}
+}
+
+// runDependency runs fn. Dependencies are run through it, so that Deps can
+// tell from the stack whether it was called by one.
+func runDependency(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
+
+var runDependencyName = funcName(runDependency)
+
+// inDependency reports whether the caller is running on the stack of a
+// dependency.
+func inDependency() bool {
+	pcs := make([]uintptr, 256)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if frame.Function == runDependencyName {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// limits holds the semaphores used by Limit, by name.
+var limits = struct {
+	mu sync.Mutex
+	m  map[string]*semaphore
+}{m: map[string]*semaphore{}}
+
+func namedSemaphore(name string) *semaphore {
+	defer limits.mu.Unlock()
+	limits.mu.Lock()
+
+	s, ok := limits.m[name]
+	if !ok {
+		s = newSemaphore()
+		limits.m[name] = s
+	}
+	return s
+}
+
+// Limit wraps fn so that at most n functions limited with the same name run at
+// the same time, no matter how many dependencies may run in parallel. This is
+// useful for dependencies that share a scarce resource, for example to build
+// only one docker image at a time:
+//
+//	mg.Deps(mg.Limit("docker", 1, ServerImage), mg.Limit("docker", 1, ClientImage))
+//
+// Fn may be anything accepted by Deps. The returned Fn has the same name and ID
+// as fn, so it is still only run once, however it is referenced. Functions
+// sharing a name should use the same n, and should not depend on each other,
+// since a function holds its slot until it returns.
+func Limit(name string, n int, fn interface{}) Fn {
+	if n < 1 {
+		panic(fmt.Errorf("mg.Limit for %q must allow at least 1 function at a time, got %d", name, n))
+	}
+	return limitFn{
+		Fn:  checkFns([]interface{}{fn})[0],
+		sem: namedSemaphore(name),
+		n:   n,
+	}
+}
+
+type limitFn struct {
+	Fn
+	sem *semaphore
+	n   int
+}
+
+// Run runs the function once a slot of its semaphore is free.
+func (l limitFn) Run(ctx context.Context) error {
+	l.sem.acquire(l.n)
+	defer l.sem.release()
+	return l.Fn.Run(ctx)
+}
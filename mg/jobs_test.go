@@ -0,0 +1,180 @@
+package mg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrency tracks the maximum number of functions running at once.
+type concurrency struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (c *concurrency) run() {
+	c.start()
+	time.Sleep(20 * time.Millisecond)
+	c.stop()
+}
+
+func (c *concurrency) start() {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+}
+
+func (c *concurrency) stop() {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	c.running--
+}
+
+func TestDepsJobs(t *testing.T) {
+	t.Setenv("MAGEFILE_JOBS", "2")
+	c := &concurrency{}
+	Deps(
+		F(func(int) { c.run() }, 1),
+		F(func(int) { c.run() }, 2),
+		F(func(int) { c.run() }, 3),
+		F(func(int) { c.run() }, 4),
+		F(func(int) { c.run() }, 5),
+	)
+	if c.max != 2 {
+		t.Fatalf("expected at most 2 dependencies to run at once, but got %d", c.max)
+	}
+}
+
+func TestDepsJobsNested(t *testing.T) {
+	t.Setenv("MAGEFILE_JOBS", "1")
+	leaf := func(string) {}
+	helper := func(what string) {
+		Deps(F(leaf, what+"-helper"))
+	}
+	mid := func(what string) {
+		Deps(F(leaf, what))
+		helper(what)
+	}
+	done := make(chan struct{})
+	go func() {
+		Deps(F(mid, "a"), F(mid, "b"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nested dependencies deadlocked with a single job")
+	}
+}
+
+func TestDepsJobsGoroutine(t *testing.T) {
+	t.Setenv("MAGEFILE_JOBS", "1")
+	leaf := func(string) {}
+	mid := func(ctx context.Context, what string) {
+		done := make(chan struct{})
+		go func() {
+			CtxDeps(ctx, F(leaf, what))
+			close(done)
+		}()
+		<-done
+	}
+	done := make(chan struct{})
+	go func() {
+		Deps(F(mid, "goroutine"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dependencies declared from a goroutine deadlocked with a single job")
+	}
+}
+
+// collision is used by collidingDep.
+var collision = struct {
+	c                *concurrency
+	started, release chan struct{}
+}{}
+
+// collidingDep holds its job until released when run as a dependency, and
+// otherwise declares a dependency of its own.
+func collidingDep(what string) {
+	if what != "dep" {
+		Deps(F(func(int) { collision.c.run() }, 1))
+		return
+	}
+	collision.c.start()
+	close(collision.started)
+	<-collision.release
+	collision.c.stop()
+}
+
+func TestDepsJobsNameCollision(t *testing.T) {
+	t.Setenv("MAGEFILE_JOBS", "1")
+	collision.c = &concurrency{}
+	collision.started, collision.release = make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Deps(F(collidingDep, "dep"))
+		close(done)
+	}()
+	<-collision.started
+
+	// a function that isn't run as a dependency holds no job to give up,
+	// even though a dependency with the same name is running.
+	plain := make(chan struct{})
+	go func() {
+		collidingDep("plain")
+		close(plain)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(collision.release)
+	<-done
+	<-plain
+	if collision.c.max != 1 {
+		t.Fatalf("expected at most 1 function to run at once, but got %d", collision.c.max)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	c := &concurrency{}
+	other := &concurrency{}
+	Deps(
+		Limit("test", 1, F(func(int) { c.run() }, 1)),
+		Limit("test", 1, F(func(int) { c.run() }, 2)),
+		Limit("test", 1, F(func(int) { c.run() }, 3)),
+		F(func(int) { other.run() }, 1),
+		F(func(int) { other.run() }, 2),
+	)
+	if c.max != 1 {
+		t.Fatalf("expected at most 1 limited dependency to run at once, but got %d", c.max)
+	}
+	if other.max != 2 {
+		t.Fatalf("expected unlimited dependencies to run in parallel, but got %d at once", other.max)
+	}
+}
+
+func TestLimitKeepsIdentity(t *testing.T) {
+	f := func() {}
+	l := Limit("test", 1, f)
+	if l.Name() != funcName(f) {
+		t.Fatalf("expected name %q, but got %q", funcName(f), l.Name())
+	}
+	if l.ID() != F(f).ID() {
+		t.Fatalf("expected ID %q, but got %q", F(f).ID(), l.ID())
+	}
+}
+
+func TestLimitInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected a panic for a limit of 0")
+		}
+	}()
+	Limit("test", 0, func() {})
+}
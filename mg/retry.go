@@ -0,0 +1,104 @@
+package mg
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetryOption configures how Retry re-runs a function.
+type RetryOption func(*retryPolicy)
+
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+	codes    []int
+}
+
+// Attempts sets how many times Retry runs a function before giving up,
+// including the first run. It defaults to 3.
+func Attempts(n int) RetryOption {
+	if n < 1 {
+		panic(fmt.Errorf("mg.Attempts must allow at least 1 attempt, got %d", n))
+	}
+	return func(p *retryPolicy) {
+		p.attempts = n
+	}
+}
+
+// Backoff sets how long Retry waits before running a function again after its
+// first failure. The wait doubles after each failure after that. It defaults
+// to no wait at all.
+func Backoff(d time.Duration) RetryOption {
+	return func(p *retryPolicy) {
+		p.backoff = d
+	}
+}
+
+// RetryOn makes Retry re-run a function only when it fails with one of the
+// given exit codes, see ExitStatus. By default it re-runs a function whatever
+// it failed with.
+func RetryOn(codes ...int) RetryOption {
+	return func(p *retryPolicy) {
+		p.codes = append(p.codes, codes...)
+	}
+}
+
+// Retry wraps fn so that it is run again when it fails, for dependencies that
+// fail intermittently:
+//
+//	mg.Deps(mg.Retry(mg.F(PullImage, "postgres"), mg.Attempts(5), mg.Backoff(time.Second)))
+//
+// Fn may be anything accepted by Deps. The returned Fn has the same name and ID
+// as fn, so it is still only run once however it is referenced, and only the
+// error of the last attempt is kept. Each failed attempt is logged. Retry does
+// not re-run a function that panicked, and stops waiting to re-run it when the
+// context is cancelled.
+func Retry(fn interface{}, opts ...RetryOption) Fn {
+	r := retryFn{
+		Fn:          checkFns([]interface{}{fn})[0],
+		retryPolicy: retryPolicy{attempts: 3},
+	}
+	for _, opt := range opts {
+		opt(&r.retryPolicy)
+	}
+	return r
+}
+
+type retryFn struct {
+	Fn
+	retryPolicy
+}
+
+// Run runs the function until it succeeds, fails with an exit code that isn't
+// retried, or runs out of attempts.
+func (r retryFn) Run(ctx context.Context) error {
+	wait := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := r.Fn.Run(ctx)
+		if err == nil || attempt >= r.attempts || !r.retries(err) {
+			return err
+		}
+		logger.Printf("Dependency %s failed (attempt %d of %d), retrying in %v: %v", displayName(r.Name()), attempt, r.attempts, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// retries reports whether a function that failed with err should be re-run.
+func (p retryPolicy) retries(err error) bool {
+	if len(p.codes) == 0 {
+		return true
+	}
+	code := ExitStatus(err)
+	for _, c := range p.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package mg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	buf := &bytes.Buffer{}
+	defaultLogger := logger
+	logger = log.New(buf, "", 0)
+	defer func() { logger = defaultLogger }()
+
+	runs := 0
+	flaky := func(string) error {
+		runs++
+		if runs < 3 {
+			return errors.New("flaked")
+		}
+		return nil
+	}
+	dep := Retry(F(flaky, "retry"), Attempts(3), Backoff(time.Millisecond))
+	Deps(dep, dep)
+	Deps(dep)
+	if runs != 3 {
+		t.Fatalf("expected 3 runs, but got %d", runs)
+	}
+	if n := strings.Count(buf.String(), "failed (attempt "); n != 2 {
+		t.Fatalf("expected 2 failed attempts to be logged, but got:\n%s", buf)
+	}
+	if !strings.Contains(buf.String(), "(attempt 1 of 3), retrying in 1ms: flaked") {
+		t.Fatalf("expected the first attempt to be logged, but got:\n%s", buf)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	runs := 0
+	failing := func(string) error {
+		runs++
+		return Fatal(4, "still failing")
+	}
+	err := Retry(F(failing, "gives up"), Attempts(2)).Run(context.Background())
+	if runs != 2 {
+		t.Fatalf("expected 2 runs, but got %d", runs)
+	}
+	if ExitStatus(err) != 4 {
+		t.Fatalf("expected the error of the last attempt, but got %v", err)
+	}
+}
+
+func TestRetryOn(t *testing.T) {
+	runs := 0
+	failing := func(string) error {
+		runs++
+		return Fatal(2, "not retried")
+	}
+	err := Retry(F(failing, "retry on"), RetryOn(3, 4)).Run(context.Background())
+	if runs != 1 {
+		t.Fatalf("expected 1 run for an exit code that isn't retried, but got %d", runs)
+	}
+	if ExitStatus(err) != 2 {
+		t.Fatalf("expected exit code 2, but got %v", err)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	failing := func(string) error {
+		runs++
+		cancel()
+		return errors.New("failed")
+	}
+	err := Retry(F(failing, "cancelled"), Backoff(time.Hour)).Run(ctx)
+	if runs != 1 || err == nil {
+		t.Fatalf("expected 1 failed run once the context is cancelled, but got %d runs and %v", runs, err)
+	}
+}
@@ -71,6 +71,59 @@ const MultilineEnv = "MAGEFILE_MULTILINE"
 // - BrightWhite.
 const TargetColorEnv = "MAGEFILE_TARGET_COLOR"
 
+// GraphEnv is the environment variable that indicates the user requested the
+// dependency graph of the targets run to be written to the given file, like
+// mage's -graph flag. The graph is written as JSON if the file has a .json
+// extension, and in the Graphviz DOT language otherwise.
+const GraphEnv = "MAGEFILE_GRAPH"
+
+// GraphFileEnv is the environment variable mage uses to tell the compiled
+// magefile where to record the edges of the dependency graph as they are
+// added. It is set by mage when the dependency graph was requested, and mage
+// writes the graph once the compiled magefile exits.
+const GraphFileEnv = "MAGEFILE_GRAPH_FILE"
+
+// TimingsEnv is the environment variable that indicates the user requested a
+// summary of how long each target and dependency took to run, printed by mage
+// after the compiled magefile exits.
+const TimingsEnv = "MAGEFILE_TIMINGS"
+
+// TimingsFileEnv is the environment variable mage uses to tell the compiled
+// magefile where to record the timings of targets and dependencies. It is set
+// by mage when the timings summary was requested.
+const TimingsFileEnv = "MAGEFILE_TIMINGS_FILE"
+
+// WatchFileEnv is the environment variable mage uses to tell the compiled
+// magefile where to record the sources passed to package target, so that mage
+// -watch runs the targets again when they change. It is set by mage -watch.
+const WatchFileEnv = "MAGEFILE_WATCH_FILE"
+
+// JobsEnv is the environment variable that indicates the maximum number of
+// dependencies the user wants to run at the same time. Zero, or no value,
+// means there is no limit.
+const JobsEnv = "MAGEFILE_JOBS"
+
+// FailFastEnv is the environment variable that indicates the user requested
+// that as soon as one dependency fails, the context passed to the other
+// dependencies run by the same call to Deps or CtxDeps is cancelled.
+const FailFastEnv = "MAGEFILE_FAILFAST"
+
+// KeepGoingEnv is the environment variable that indicates the user requested
+// that all the targets given on the command line be run, even if some of them
+// fail.
+const KeepGoingEnv = "MAGEFILE_KEEPGOING"
+
+// TraceEnv is the environment variable that indicates the user requested a
+// trace of the targets, dependencies and commands run, exported as OTLP/JSON to
+// the given file, or to the given http(s) endpoint of a collector, such as
+// http://localhost:4318/v1/traces.
+const TraceEnv = "MAGEFILE_TRACE"
+
+// TraceArgsEnv is the environment variable that indicates the user requested
+// the arguments of commands run with package sh to be recorded in traces. They
+// are left out otherwise, since they often hold tokens and passwords.
+const TraceArgsEnv = "MAGEFILE_TRACE_ARGS"
+
 // Verbose reports whether a magefile was run with the verbose flag.
 func Verbose() bool {
 	b, _ := strconv.ParseBool(os.Getenv(VerboseEnv))
@@ -106,6 +159,37 @@ func Multiline() bool {
 	return b
 }
 
+// Timings reports whether the user has requested a summary of how long each
+// target and dependency took to run.
+func Timings() bool {
+	b, _ := strconv.ParseBool(os.Getenv(TimingsEnv))
+	return b
+}
+
+// FailFast reports whether the user has requested that the remaining
+// dependencies be cancelled as soon as one of them fails.
+func FailFast() bool {
+	b, _ := strconv.ParseBool(os.Getenv(FailFastEnv))
+	return b
+}
+
+// KeepGoing reports whether the user has requested that all targets be run
+// even if some of them fail.
+func KeepGoing() bool {
+	b, _ := strconv.ParseBool(os.Getenv(KeepGoingEnv))
+	return b
+}
+
+// Jobs returns the maximum number of dependencies that may run at the same
+// time, or 0 if there is no limit.
+func Jobs() int {
+	n, _ := strconv.Atoi(os.Getenv(JobsEnv))
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
 // IgnoreDefault reports whether the user has requested to ignore the default target
 // in the magefile.
 func IgnoreDefault() bool {
@@ -113,6 +197,25 @@ func IgnoreDefault() bool {
 	return b
 }
 
+// GraphFile returns the file the dependency graph should be written to, or an
+// empty string if the user did not request one.
+func GraphFile() string {
+	return os.Getenv(GraphEnv)
+}
+
+// Trace returns the file or http(s) endpoint the trace of the targets run
+// should be exported to, or an empty string if the user did not request one.
+func Trace() string {
+	return os.Getenv(TraceEnv)
+}
+
+// TraceArgs reports whether the user requested the arguments of commands to be
+// recorded in traces.
+func TraceArgs() bool {
+	b, _ := strconv.ParseBool(os.Getenv(TraceArgsEnv))
+	return b
+}
+
 // CacheDir returns the directory where mage caches compiled binaries.  It
 // defaults to $HOME/.magefile, but may be overridden by the MAGEFILE_CACHE
 // environment variable.
@@ -0,0 +1,102 @@
+package mg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutExitCode is the exit code of a dependency that didn't finish within
+// the time given to WithTimeout. It is the same as that of the timeout command,
+// so that it can be told apart from ordinary failures.
+const TimeoutExitCode = 124
+
+// WithTimeout wraps fn so that it fails if it doesn't finish within d:
+//
+//	mg.Deps(mg.WithTimeout(5*time.Minute, IntegrationTests))
+//
+// Fn may be anything accepted by Deps. The context passed to fn is cancelled
+// once d has passed, and the dependency fails straight away with an error
+// naming it and exit code TimeoutExitCode, even if fn doesn't return. The
+// returned Fn has the same name and ID as fn, so it is still only run once
+// however it is referenced.
+func WithTimeout(d time.Duration, fn interface{}) Fn {
+	if d <= 0 {
+		panic(fmt.Errorf("mg.WithTimeout must be given a positive timeout, got %v", d))
+	}
+	return timeoutFn{
+		Fn:      checkFns([]interface{}{fn})[0],
+		timeout: d,
+	}
+}
+
+type timeoutFn struct {
+	Fn
+	timeout time.Duration
+}
+
+// Run runs the function, and returns a timeoutError if it is still running
+// once its timeout has passed.
+func (t timeoutFn) Run(ctx context.Context) error {
+	tctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	type result struct {
+		err      error
+		panicked bool
+		v        interface{}
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- result{panicked: true, v: v}
+			}
+		}()
+		// calls to Deps from fn give up the job of the dependency, like
+		// they would without the timeout.
+		done <- result{err: runDependency(tctx, t.Fn.Run)}
+	}()
+
+	// fn returning because its context was done is a timeout too, unless it
+	// was the caller's context that was done, in which case fn gets to finish
+	// the way it would without the timeout.
+	timedOut := func() bool {
+		return errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	}
+	var r result
+	select {
+	case r = <-done:
+	case <-tctx.Done():
+		if !timedOut() {
+			r = <-done
+		}
+	}
+	if timedOut() {
+		return timeoutError{name: DepNode{Name: t.Name(), ID: t.ID()}.String(), timeout: t.timeout}
+	}
+	if r.panicked {
+		panic(r.v)
+	}
+	return r.err
+}
+
+// timeoutError is the error of a dependency that exceeded its timeout.
+type timeoutError struct {
+	name    string
+	timeout time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("dependency %s timed out after %v", e.name, e.timeout)
+}
+
+func (e timeoutError) ExitStatus() int {
+	return TimeoutExitCode
+}
+
+// Unwrap makes errors.Is(err, context.DeadlineExceeded) report true.
+func (e timeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
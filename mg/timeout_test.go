@@ -0,0 +1,105 @@
+package mg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	slow := func(ctx context.Context, name string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	err := WithTimeout(10*time.Millisecond, F(slow, "server")).Run(context.Background())
+	if ExitStatus(err) != TimeoutExitCode {
+		t.Fatalf("expected exit code %d, but got %d from %v", TimeoutExitCode, ExitStatus(err), err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to be a deadline exceeded, but got %v", err)
+	}
+	expected := `dependency github.com/magefile/mage/mg.TestWithTimeout.func1("server") timed out after 10ms`
+	if err.Error() != expected {
+		t.Errorf("expected %q, but got %q", expected, err)
+	}
+}
+
+func TestWithTimeoutReturningContextError(t *testing.T) {
+	// fn often returns before the timeout is noticed, which must not make a
+	// difference.
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	for i := 0; i < 1000; i++ {
+		err := WithTimeout(time.Nanosecond, slow).Run(context.Background())
+		if ExitStatus(err) != TimeoutExitCode {
+			t.Fatalf("expected exit code %d, but got %d from %v", TimeoutExitCode, ExitStatus(err), err)
+		}
+	}
+}
+
+func TestWithTimeoutIgnoringContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	stuck := func() { <-block }
+	err := WithTimeout(10*time.Millisecond, stuck).Run(context.Background())
+	if ExitStatus(err) != TimeoutExitCode {
+		t.Fatalf("expected a dependency ignoring its context to time out, but got %v", err)
+	}
+}
+
+func TestWithTimeoutDeps(t *testing.T) {
+	fast := func(string) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	defer func() {
+		v := recover()
+		err, ok := v.(error)
+		if !ok {
+			t.Fatalf("expected a panic with an error, but got %v", v)
+		}
+		if ExitStatus(err) != TimeoutExitCode {
+			t.Errorf("expected exit code %d, but got %d", TimeoutExitCode, ExitStatus(err))
+		}
+		if !strings.Contains(err.Error(), "timed out after 10ms") {
+			t.Errorf("expected the timeout in the error, but got %q", err)
+		}
+	}()
+	Deps(WithTimeout(time.Minute, F(fast, "timeout deps")), WithTimeout(10*time.Millisecond, slow))
+}
+
+func TestWithTimeoutCallerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("cancelled")
+	}
+	err := WithTimeout(time.Minute, slow).Run(ctx)
+	if err == nil || err.Error() != "cancelled" {
+		t.Fatalf("expected the dependency's own error when the caller is cancelled, but got %v", err)
+	}
+}
+
+func TestWithTimeoutJobs(t *testing.T) {
+	t.Setenv("MAGEFILE_JOBS", "1")
+	leaf := func() {}
+	mid := func() {
+		Deps(leaf)
+	}
+	done := make(chan struct{})
+	go func() {
+		Deps(WithTimeout(time.Minute, mid))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dependencies of a function with a timeout deadlocked with a single job")
+	}
+}
@@ -0,0 +1,71 @@
+package mg
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/magefile/mage/internal"
+)
+
+// recordTiming appends the timing of a call to a dependency to the file mage
+// asked for with MAGEFILE_TIMINGS_FILE, if any.
+func recordTiming(key onceKey, parent DepNode, start time.Time, cached, failed bool, err error) {
+	path := os.Getenv(TimingsFileEnv)
+	if path == "" {
+		return
+	}
+	t := internal.Timing{
+		Name:       key.Name,
+		ID:         key.ID,
+		ParentName: parent.Name,
+		ParentID:   parent.ID,
+		Cached:     cached,
+		Failed:     failed,
+		Start:      start,
+		End:        time.Now(),
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if err := internal.AppendTiming(path, t); err != nil {
+		logger.Println("warning: can't record timings:", err)
+	}
+}
+
+// RecordCommand records a command run by package sh for the timings and traces
+// mage was asked for with MAGEFILE_TIMINGS_FILE, if any, as a child of the
+// dependency running it. The arguments of the command are only recorded if
+// MAGEFILE_TRACE_ARGS is set, since they often hold tokens and passwords.
+func RecordCommand(cmd string, args []string, start time.Time, exitCode int, err error) {
+	path := os.Getenv(TimingsFileEnv)
+	if path == "" {
+		return
+	}
+	// skip the frames of package sh to get to the function running the
+	// command.
+	callers := callerNames()
+	for len(callers) > 0 && strings.HasPrefix(callers[0], "github.com/magefile/mage/sh.") {
+		callers = callers[1:]
+	}
+	parent, _ := depParent(callers)
+	t := internal.Timing{
+		Name:       "exec",
+		Cmd:        cmd,
+		ParentName: parent.Name,
+		ParentID:   parent.ID,
+		ExitCode:   exitCode,
+		Failed:     err != nil,
+		Start:      start,
+		End:        time.Now(),
+	}
+	if TraceArgs() {
+		t.Args = args
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if err := internal.AppendTiming(path, t); err != nil {
+		logger.Println("warning: can't record timings:", err)
+	}
+}
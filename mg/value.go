@@ -0,0 +1,88 @@
+package mg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Value runs fn as a dependency of the calling function and returns the value
+// it returned. Like any other dependency, fn is run exactly once, no matter how
+// many times or from where Value is called with it, and every caller gets the
+// same value and error. This is useful for sharing results between targets,
+// such as a computed version string:
+//
+//	func version(ctx context.Context) (string, error) {
+//		return sh.Output("git", "describe", "--tags")
+//	}
+//
+//	func Build(ctx context.Context) error {
+//		v, err := mg.Value(ctx, version)
+//		if err != nil {
+//			return err
+//		}
+//		return sh.Run("go", "build", "-ldflags=-X main.version="+v)
+//	}
+//
+// If fn fails, the zero value of T is returned along with its error. The given
+// context is passed to fn, if it is the call that runs it.
+func Value[T any](ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	f := &valueFn[T]{name: funcName(fn), fn: fn}
+
+	// runDeps reports failures by panicking, but the error of the function
+	// itself is more useful to callers, so only fall back to the panic for
+	// functions that panicked.
+	var panicked error
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				if err, ok := v.(error); ok {
+					panicked = err
+				} else {
+					panicked = fmt.Errorf("%v", v)
+				}
+			}
+		}()
+		runDeps(ctx, callerNames(), []Fn{f}, false)
+	}()
+
+	var zero T
+	o := onces.LoadOrStore(f)
+	if o.err != nil {
+		return zero, o.err
+	}
+	if panicked != nil {
+		return zero, panicked
+	}
+	// the function was run through the first Fn stored for it, which holds
+	// the value.
+	if ran, ok := o.fn.(*valueFn[T]); ok && ran.done {
+		return ran.value, nil
+	}
+	return zero, fmt.Errorf("dependency %s did not return a value", displayName(f.name))
+}
+
+// valueFn is the mg.Fn for a function run with Value. It keeps the value the
+// function returned.
+type valueFn[T any] struct {
+	name  string
+	fn    func(context.Context) (T, error)
+	value T
+	done  bool // whether the function returned without an error
+}
+
+// Name returns the fully qualified name of the function.
+func (f *valueFn[T]) Name() string {
+	return f.name
+}
+
+// ID returns the same ID as a function without arguments passed to F.
+func (f *valueFn[T]) ID() string {
+	return "null"
+}
+
+// Run runs the function and keeps the value it returned.
+func (f *valueFn[T]) Run(ctx context.Context) error {
+	v, err := f.fn(ctx)
+	f.value, f.done = v, err == nil
+	return err
+}
@@ -0,0 +1,86 @@
+package mg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	var ran int64
+	version := func(context.Context) (string, error) {
+		atomic.AddInt64(&ran, 1)
+		return "v1.2.3", nil
+	}
+	ctx := context.Background()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := Value(ctx, version)
+			if err != nil {
+				t.Error("unexpected error:", err)
+			}
+			if v != "v1.2.3" {
+				t.Errorf("expected v1.2.3, but got %q", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if ran != 1 {
+		t.Fatalf("expected version to run once, but it ran %d times", ran)
+	}
+}
+
+func TestValueError(t *testing.T) {
+	errOops := Fatal(3, "oops")
+	packages := func(context.Context) ([]string, error) {
+		return []string{"partial"}, errOops
+	}
+	for i := 0; i < 2; i++ {
+		v, err := Value(context.Background(), packages)
+		if !errors.Is(err, errOops) {
+			t.Fatalf("expected the error of the function, but got %v", err)
+		}
+		if v != nil {
+			t.Fatalf("expected no value on error, but got %v", v)
+		}
+	}
+}
+
+func TestValuePanic(t *testing.T) {
+	sha := func(context.Context) (string, error) {
+		panic("boom")
+	}
+	_, err := Value(context.Background(), sha)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected error boom, but got %v", err)
+	}
+
+	// the function isn't run again, but callers still mustn't get the zero
+	// value without an error.
+	_, err = Value(context.Background(), sha)
+	if err == nil {
+		t.Fatal("expected an error from a function that panicked before")
+	}
+}
+
+func TestValueInDeps(t *testing.T) {
+	count := func(context.Context) (int, error) {
+		return 42, nil
+	}
+	var got int64
+	user := func(ctx context.Context) error {
+		v, err := Value(ctx, count)
+		atomic.AddInt64(&got, int64(v))
+		return err
+	}
+	Deps(F(func(ctx context.Context, _ int) error { return user(ctx) }, 1),
+		F(func(ctx context.Context, _ int) error { return user(ctx) }, 2))
+	if got != 84 {
+		t.Fatalf("expected both dependencies to get 42, but got %d in total", got)
+	}
+}
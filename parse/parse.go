@@ -8,12 +8,16 @@ import (
 	"go/doc"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"io"
 	"log"
 	"os"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/magefile/mage/internal"
 )
@@ -22,6 +26,24 @@ const importTag = "mage:import"
 
 const multilineTag = "mage:multiline"
 
+const namespaceGroupsTag = "mage:namespacegroups"
+
+const enumTag = "mage:enum"
+
+const defaultTag = "mage:default"
+
+const envTag = "mage:env"
+
+const hiddenTag = "mage:hidden"
+
+const internalTag = "mage:internal"
+
+const groupTag = "mage:group"
+
+const watchTag = "mage:watch"
+
+const deprecatedPrefix = "Deprecated:"
+
 var debug = log.New(io.Discard, "DEBUG: ", log.Ltime|log.Lmicroseconds)
 
 // EnableDebug turns on debug logging.
@@ -34,12 +56,33 @@ func EnableDebug() {
 type PkgInfo struct {
 	AstPkg      *ast.Package
 	DocPkg      *doc.Package
+	FileSet     *token.FileSet // the file set of the positions in AstPkg
 	Description string
 	Funcs       Functions
 	DefaultFunc *Function
 	Aliases     map[string]*Function
 	Imports     Imports
 	Multiline   bool
+	HasHooks    bool // the package declares a Hooks variable of type mg.Hooks
+
+	// NamespaceGroups is true when the package has a mage:namespacegroups
+	// comment, which lists the targets of each namespace under its own group.
+	NamespaceGroups bool
+
+	// DefaultPosition is where the Default variable is declared, and
+	// AliasPositions where each alias is in the Aliases variable.
+	DefaultPosition token.Position
+	AliasPositions  map[string]token.Position
+}
+
+// position returns the position of p in the parsed files. A PkgInfo that
+// wasn't made by Package may have no FileSet, in which case it returns the zero
+// Position.
+func (pi *PkgInfo) position(p token.Pos) token.Position {
+	if pi.FileSet == nil {
+		return token.Position{}
+	}
+	return pi.FileSet.Position(p)
 }
 
 // Function represents a job function from a mage file.
@@ -53,7 +96,17 @@ type Function struct {
 	IsContext  bool
 	Synopsis   string // Synopsis is a one sentence description of the function, without its leading function name.
 	Comment    string // Comment is the full comment on the function, with newlines replaced by spaces and trimmed.
+	Hidden     bool   // Hidden is true for targets annotated with mage:hidden, which can be run but aren't listed.
+	Group      string // Group is the heading the target is listed under, if any.
+	Deprecated string // Deprecated is the text of the "Deprecated:" paragraph of the comment, if any, usually naming a replacement.
+	File       string // File is the path of the file the function is declared in.
+	Line       int    // Line is the line the name of the function is on.
+	Column     int    // Column is the column the name of the function starts at, in bytes.
 	Args       []Arg
+
+	// Watch are the globs of the files mage -watch runs the target again
+	// for, from mage:watch annotations.
+	Watch []string
 }
 
 var _ sort.Interface = Functions(nil)
@@ -76,9 +129,78 @@ func (s Functions) Swap(i, j int) {
 
 // Arg is an argument to a Function.
 type Arg struct {
-	Name, Type string
-	Optional   bool
-	Comment    string
+	Name, Type      string
+	Optional        bool
+	Comment         string
+	TextUnmarshaler bool     // Type is declared in the package and implements encoding.TextUnmarshaler
+	Choices         []string // the values allowed for a string argument, if restricted with mage:enum
+	Default         string   // the value used for an option that isn't given, if any
+	Env             string   // the environment variable read for an option that isn't given, if any
+	Fields          []Arg    // the options of an options struct argument
+	Field           string   // the name of the struct field of an option from an options struct
+}
+
+// ValueLabel returns how the value of the argument is shown in help text: its
+// choices, if restricted, or its type.
+func (a Arg) ValueLabel() string {
+	if len(a.Choices) > 0 {
+		return strings.Join(a.Choices, "|")
+	}
+	return a.Type
+}
+
+// FlagLabel returns how an optional argument is shown in the flag docs of its
+// target, e.g. -count=<int>.
+func (a Arg) FlagLabel() string {
+	return fmt.Sprintf("-%s=<%s>", a.Name, a.ValueLabel())
+}
+
+// FlagComment returns the comment of an optional argument as shown in the flag
+// docs of its target, followed by its environment variable and default, if any.
+func (a Arg) FlagComment() string {
+	var notes []string
+	if a.Env != "" {
+		notes = append(notes, "env: $"+a.Env)
+	}
+	if a.Default != "" {
+		notes = append(notes, "default: "+a.Default)
+	}
+	if len(notes) == 0 {
+		return a.Comment
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s (%s)", a.Comment, strings.Join(notes, ", ")))
+}
+
+// ListSynopsis returns the synopsis as it is shown when listing targets, which
+// marks deprecated targets.
+func (f Function) ListSynopsis() string {
+	if f.Deprecated == "" {
+		return f.Synopsis
+	}
+	return strings.TrimSpace(f.Synopsis + " (deprecated)")
+}
+
+// DeprecationWarning returns the warning printed when a deprecated target is
+// run, or "" if it isn't deprecated.
+func (f Function) DeprecationWarning() string {
+	if f.Deprecated == "" {
+		return ""
+	}
+	return fmt.Sprintf("Warning: target %q is deprecated: %s", strings.ToLower(f.TargetName()), f.Deprecated)
+}
+
+// Position returns where the function is declared.
+func (f Function) Position() token.Position {
+	return token.Position{Filename: f.File, Line: f.Line, Column: f.Column}
+}
+
+// withPosition returns s followed by the position of f, if it is known, for
+// error messages.
+func withPosition(s string, f *Function) string {
+	if f.File == "" {
+		return s
+	}
+	return fmt.Sprintf("%s (%s)", s, f.Position())
 }
 
 // ID returns user-readable information about where this function is defined.
@@ -129,12 +251,17 @@ func (f Function) RequiredArgs() []Arg {
 	return out
 }
 
-// OptionalArgs returns only the optional arguments.
+// OptionalArgs returns only the optional arguments, with options structs
+// replaced by their fields.
 func (f Function) OptionalArgs() []Arg {
 	var out []Arg
 	for _, a := range f.Args {
 		if a.Optional {
-			out = append(out, a)
+			if a.Fields != nil {
+				out = append(out, a.Fields...)
+			} else {
+				out = append(out, a)
+			}
 		}
 	}
 	return out
@@ -152,28 +279,19 @@ func (f Function) HasOptionalArgs() bool {
 
 // MultipleOptionalArgs reports whether the function has more than one optional argument.
 func (f Function) MultipleOptionalArgs() bool {
-	n := 0
-	for _, a := range f.Args {
-		if a.Optional {
-			n++
-			if n > 1 {
-				return true
-			}
-		}
-	}
-	return false
+	return len(f.OptionalArgs()) > 1
 }
 
 // ShowFlagDocs reports whether the Flags section should be displayed.
 // This is true when there are multiple optional args (since they are
 // condensed to [<flags>] in the usage line) or when any optional arg
-// has a doc comment.
+// has a doc comment, a default or an environment variable.
 func (f Function) ShowFlagDocs() bool {
 	if f.MultipleOptionalArgs() {
 		return true
 	}
-	for _, a := range f.Args {
-		if a.Optional && a.Comment != "" {
+	for _, a := range f.OptionalArgs() {
+		if a.Comment != "" || a.Default != "" || a.Env != "" {
 			return true
 		}
 	}
@@ -195,11 +313,11 @@ func (f Function) FlagDocsString() string {
 	var entries []entry
 	maxLen := 0
 	for _, a := range opts {
-		label := fmt.Sprintf("-%s=<%s>", a.Name, a.Type)
+		label := a.FlagLabel()
 		if len(label) > maxLen {
 			maxLen = len(label)
 		}
-		entries = append(entries, entry{label: label, comment: a.Comment})
+		entries = append(entries, entry{label: label, comment: a.FlagComment()})
 	}
 
 	var buf strings.Builder
@@ -215,6 +333,152 @@ func (f Function) FlagDocsString() string {
 	return buf.String()
 }
 
+// option is an optional argument or a field of an options struct, as parsed
+// from a -name=value flag.
+type option struct {
+	Arg
+	dst string // the variable or struct field the value is stored in
+	ptr bool   // dst is a pointer, set to point to the value
+}
+
+// options returns the optional arguments of the function, and the fields of
+// its options struct.
+func (f Function) options() []option {
+	var opts []option
+	for x, a := range f.Args {
+		if !a.Optional {
+			continue
+		}
+		if a.Fields == nil {
+			opts = append(opts, option{Arg: a, dst: fmt.Sprintf("arg%d", x), ptr: true})
+			continue
+		}
+		for _, field := range a.Fields {
+			opts = append(opts, option{Arg: field, dst: fmt.Sprintf("arg%d.%s", x, field.Field)})
+		}
+	}
+	return opts
+}
+
+// optionCode returns code that parses the value of an option in _optVal, and
+// stores it.
+func (f Function) optionCode(o option) string {
+	store := func(val string) string {
+		if o.ptr {
+			val = "&" + val
+		}
+		return fmt.Sprintf(`
+						%s = %s`, o.dst, val)
+	}
+	var b strings.Builder
+	switch {
+	case o.TextUnmarshaler:
+		_, _ = fmt.Fprintf(&b, `
+						var _tmp %s
+						if err := _tmp.UnmarshalText([]byte(_optVal)); err != nil {
+							logger.Printf("can't convert option %%q value %%q to %s: %%v\n", _optName, _optVal, err)
+							_os.Exit(2)
+						}`, f.argType(o.Arg), o.Type)
+		_, _ = b.WriteString(store("_tmp"))
+	case o.Type == "string":
+		_, _ = b.WriteString(f.enumCode(o.Arg, "_optVal"))
+		_, _ = b.WriteString(`
+						_tmp := _optVal`)
+		_, _ = b.WriteString(store("_tmp"))
+	case strings.HasPrefix(o.Type, "[]"):
+		// repeating the option adds to the list.
+		list := o.dst
+		if o.ptr {
+			_, _ = fmt.Fprintf(&b, `
+						if %s == nil {
+							%s = new(%s)
+						}`, o.dst, o.dst, f.argType(o.Arg))
+			list = "*" + o.dst
+		}
+		_, _ = b.WriteString(sliceCode(o.Type, "_optVal", list,
+			fmt.Sprintf(`logger.Printf("can't convert option %%q value %%q to %s\n", _optName, _optVal)`, o.Type)))
+	default:
+		_, _ = fmt.Fprintf(&b, `
+						_tmp, err := %s
+						if err != nil {
+							logger.Printf("can't convert option %%q value %%q to %s\n", _optName, _optVal)
+							_os.Exit(2)
+						}`, fmt.Sprintf(parseFuncs[o.Type], "_optVal"), o.Type)
+		_, _ = b.WriteString(store("_tmp"))
+	}
+	return b.String()
+}
+
+// enumCode returns code that exits with the list of choices if the value of
+// the expression src isn't one of the choices of the argument.
+func (f Function) enumCode(a Arg, src string) string {
+	if len(a.Choices) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(a.Choices))
+	for i, c := range a.Choices {
+		quoted[i] = fmt.Sprintf("%q", c)
+	}
+	msg := fmt.Sprintf("invalid value %%q for argument %q of target %q, must be one of: %s\n",
+		a.Name, f.TargetName(), strings.ReplaceAll(strings.Join(a.Choices, ", "), "%", "%%"))
+	return fmt.Sprintf(`
+				switch %s {
+				case %s:
+				default:
+					logger.Printf(%q, %s)
+					_os.Exit(2)
+				}`, src, strings.Join(quoted, ", "), msg, src)
+}
+
+// argType returns the type of the argument as it is written in the mainfile.
+func (f Function) argType(a Arg) string {
+	if (a.TextUnmarshaler || a.Fields != nil) && f.Package != "" {
+		return f.Package + "." + a.Type
+	}
+	return genType(a.Type)
+}
+
+// ShowArgDocs reports whether the Arguments section should be displayed.
+// This is true when a required argument has a custom type or a restricted set
+// of values, since the usage line only shows the names of required arguments.
+func (f Function) ShowArgDocs() bool {
+	for _, a := range f.Args {
+		if !a.Optional && (a.TextUnmarshaler || len(a.Choices) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArgDocsString returns a formatted string documenting the types of required
+// arguments. It aligns comments to the same column like FlagDocsString.
+func (f Function) ArgDocsString() string {
+	args := f.RequiredArgs()
+	if len(args) == 0 {
+		return ""
+	}
+	labels := make([]string, len(args))
+	maxLen := 0
+	for i, a := range args {
+		labels[i] = fmt.Sprintf("<%s> %s", a.Name, a.ValueLabel())
+		if len(labels[i]) > maxLen {
+			maxLen = len(labels[i])
+		}
+	}
+
+	var buf strings.Builder
+	_, _ = buf.WriteString("Arguments:\n\n")
+	for i, a := range args {
+		if a.Comment != "" {
+			_, _ = fmt.Fprintf(&buf, "\t%-*s  %s\n", maxLen, labels[i], a.Comment)
+		} else {
+			_, _ = fmt.Fprintf(&buf, "\t%s\n", labels[i])
+		}
+	}
+	_, _ = buf.WriteString("\n")
+	return buf.String()
+}
+
 // ExecCode returns code for the template switch to run the target.
 // It wraps each target call to match the func(context.Context) error that
 // runTarget requires.
@@ -234,11 +498,23 @@ func (f Function) ExecCode() string {
 		if arg.Optional {
 			continue
 		}
+		if arg.TextUnmarshaler {
+			_, _ = fmt.Fprintf(&parseargs, `
+				var arg%d %s
+				if err := arg%d.UnmarshalText([]byte(args.Args[x])); err != nil {
+					logger.Printf("can't convert argument %%q to %s: %%v\n", args.Args[x], err)
+					_os.Exit(2)
+				}
+				x++`, x, f.argType(arg), x, arg.Type)
+			continue
+		}
 		switch arg.Type {
 		case "string":
 			_, _ = fmt.Fprintf(&parseargs, `
-			arg%d := args.Args[x]
-			x++`, x)
+			arg%d := args.Args[x]`, x)
+			_, _ = fmt.Fprint(&parseargs, f.enumCode(arg, fmt.Sprintf("arg%d", x)))
+			_, _ = fmt.Fprint(&parseargs, `
+			x++`)
 		case "int":
 			_, _ = fmt.Fprintf(&parseargs, `
 				arg%d, err := _strconv.Atoi(args.Args[x])
@@ -271,28 +547,50 @@ func (f Function) ExecCode() string {
 					_os.Exit(2)
 				}
 				x++`, x)
+		case "[]string", "[]int", "[]float64", "[]bool", "[]time.Duration":
+			_, _ = fmt.Fprintf(&parseargs, `
+				var arg%d %s`, x, f.argType(arg))
+			_, _ = fmt.Fprint(&parseargs, sliceCode(arg.Type, "args.Args[x]", fmt.Sprintf("arg%d", x),
+				fmt.Sprintf(`logger.Printf("can't convert argument %%q to %s\n", args.Args[x])`, arg.Type)))
+			_, _ = fmt.Fprint(&parseargs, `
+				x++`)
 		default:
 			// unsupported type, skip
 		}
 	}
 
-	// Phase 2: Declare optional argument variables (nil by default)
+	// Phase 2: Declare optional argument variables (nil by default), and
+	// options structs (zero by default)
 	for x, arg := range f.Args {
 		if !arg.Optional {
 			continue
 		}
+		if arg.Fields != nil {
+			_, _ = fmt.Fprintf(&parseargs, `
+				var arg%d %s`, x, f.argType(arg))
+			continue
+		}
 		_, _ = fmt.Fprintf(&parseargs, `
-				var arg%d *%s`, x, genType(arg.Type))
+				var arg%d *%s`, x, f.argType(arg))
 	}
 
 	// Phase 3: Parse optional arguments from -name=value flags
-	if f.HasOptionalArgs() {
+	opts := f.options()
+	if len(opts) > 0 {
 		// Collect lowercase names of bool optional args for bare-flag support
 		var boolOptNames []string
-		for _, arg := range f.Args {
-			if arg.Optional && arg.Type == "bool" {
-				boolOptNames = append(boolOptNames, strings.ToLower(arg.Name))
+		hasDefaults := false
+		for _, o := range opts {
+			if o.Type == "bool" {
+				boolOptNames = append(boolOptNames, strings.ToLower(o.Name))
 			}
+			if o.Default != "" || o.Env != "" {
+				hasDefaults = true
+			}
+		}
+		if hasDefaults {
+			_, _ = fmt.Fprint(&parseargs, `
+				_seen := map[string]bool{}`)
 		}
 
 		_, _ = fmt.Fprint(&parseargs, `
@@ -317,58 +615,17 @@ func (f Function) ExecCode() string {
 					} else {
 						_optName = _strings.ToLower(_optArg[1:_eqIdx])
 						_optVal = _optArg[_eqIdx+1:]
-					}
-					switch _optName {`, f.TargetName())
-		for x, arg := range f.Args {
-			if !arg.Optional {
-				continue
-			}
-			lowerName := strings.ToLower(arg.Name)
-			switch arg.Type {
-			case "string":
-				_, _ = fmt.Fprintf(&parseargs, `
-					case %q:
-						_tmp%d := _optVal
-						arg%d = &_tmp%d`, lowerName, x, x, x)
-			case "int":
-				_, _ = fmt.Fprintf(&parseargs, `
-					case %q:
-						_tmp%d, err := _strconv.Atoi(_optVal)
-						if err != nil {
-							logger.Printf("can't convert option %%q value %%q to int\n", _optName, _optVal)
-							_os.Exit(2)
-						}
-						arg%d = &_tmp%d`, lowerName, x, x, x)
-			case "float64":
-				_, _ = fmt.Fprintf(&parseargs, `
-					case %q:
-						_tmp%d, err := _strconv.ParseFloat(_optVal, 64)
-						if err != nil {
-							logger.Printf("can't convert option %%q value %%q to float64\n", _optName, _optVal)
-							_os.Exit(2)
-						}
-						arg%d = &_tmp%d`, lowerName, x, x, x)
-			case "bool":
-				_, _ = fmt.Fprintf(&parseargs, `
-					case %q:
-						_tmp%d, err := _strconv.ParseBool(_optVal)
-						if err != nil {
-							logger.Printf("can't convert option %%q value %%q to bool\n", _optName, _optVal)
-							_os.Exit(2)
-						}
-						arg%d = &_tmp%d`, lowerName, x, x, x)
-			case "time.Duration":
-				_, _ = fmt.Fprintf(&parseargs, `
-					case %q:
-						_tmp%d, err := _time.ParseDuration(_optVal)
-						if err != nil {
-							logger.Printf("can't convert option %%q value %%q to time.Duration\n", _optName, _optVal)
-							_os.Exit(2)
-						}
-						arg%d = &_tmp%d`, lowerName, x, x, x)
-			default:
-				// unsupported type, skip
-			}
+					}`, f.TargetName())
+		if hasDefaults {
+			_, _ = fmt.Fprint(&parseargs, `
+					_seen[_optName] = true`)
+		}
+		_, _ = fmt.Fprint(&parseargs, `
+					switch _optName {`)
+		for _, o := range opts {
+			_, _ = fmt.Fprintf(&parseargs, `
+					case %q:`, strings.ToLower(o.Name))
+			_, _ = fmt.Fprint(&parseargs, f.optionCode(o))
 		}
 		_, _ = fmt.Fprintf(&parseargs, `
 					default:
@@ -377,6 +634,42 @@ func (f Function) ExecCode() string {
 					}
 					x++
 				}`, f.TargetName())
+
+		// options that weren't given are read from their environment
+		// variables, or get their defaults.
+		for _, o := range opts {
+			if o.Default == "" && o.Env == "" {
+				continue
+			}
+			name := strings.ToLower(o.Name)
+			_, _ = fmt.Fprintf(&parseargs, `
+				if !_seen[%q] {`, name)
+			if o.Env != "" {
+				// errors name the variable rather than the option.
+				_, _ = fmt.Fprintf(&parseargs, `
+					if _optVal := _os.Getenv(%q); _optVal != "" {
+						_optName := %q
+						_ = _optName`, o.Env, "$"+o.Env)
+				_, _ = fmt.Fprint(&parseargs, f.optionCode(o))
+				_, _ = fmt.Fprint(&parseargs, `
+					}`)
+				if o.Default != "" {
+					_, _ = fmt.Fprint(&parseargs, ` else {`)
+				}
+			}
+			if o.Default != "" {
+				_, _ = fmt.Fprintf(&parseargs, `
+					_optName, _optVal := %q, %q
+					_ = _optName`, name, o.Default)
+				_, _ = fmt.Fprint(&parseargs, f.optionCode(o))
+				if o.Env != "" {
+					_, _ = fmt.Fprint(&parseargs, `
+					}`)
+				}
+			}
+			_, _ = fmt.Fprint(&parseargs, `
+				}`)
+		}
 	}
 
 	out := parseargs.String() + `
@@ -399,9 +692,9 @@ func (f Function) ExecCode() string {
 		out += `
 					return nil`
 	}
-	out += `
+	out += fmt.Sprintf(`
 				}
-				ret := runTarget(logger, wrapFn)`
+				ret := runTarget(logger, %q, wrapFn)`, f.TargetName())
 	return out
 }
 
@@ -418,6 +711,7 @@ func PrimaryPackage(gocmd, path string, files []string, multiline bool) (*PkgInf
 
 	setDefault(info)
 	setAliases(info)
+	setHooks(info)
 	return info, nil
 }
 
@@ -436,9 +730,13 @@ func checkDupes(info *PkgInfo, imports []*Import) error {
 		if len(funcs[alias]) != 0 {
 			var ids []string
 			for _, f := range funcs[alias] {
-				ids = append(ids, f.ID())
+				ids = append(ids, withPosition(f.ID(), f))
+			}
+			name := fmt.Sprintf("%q", alias)
+			if pos, ok := info.AliasPositions[alias]; ok {
+				name += fmt.Sprintf(" (%s)", pos)
 			}
-			return fmt.Errorf("alias %q duplicates existing target(s): %s", alias, strings.Join(ids, ", "))
+			return fmt.Errorf("alias %s duplicates existing target(s): %s", name, strings.Join(ids, ", "))
 		}
 		funcs[alias] = append(funcs[alias], f)
 	}
@@ -455,7 +753,7 @@ func checkDupes(info *PkgInfo, imports []*Import) error {
 	for _, d := range dupes {
 		var ids []string
 		for _, f := range funcs[d] {
-			ids = append(ids, f.ID())
+			ids = append(ids, withPosition(f.ID(), f))
 		}
 		sort.Strings(ids)
 		errs = append(errs, fmt.Sprintf("%q target has multiple definitions: %s\n", d, strings.Join(ids, ", ")))
@@ -484,7 +782,7 @@ func Package(path string, files []string, multiline bool) (*PkgInfo, error) {
 	// Build a map from AST fields to their inline comments. We use
 	// ast.NewCommentMap because the Go parser does not populate
 	// ast.Field.Comment for function parameters (only for struct fields).
-	fieldComments := make(map[*ast.Field]string)
+	fieldComments := make(map[*ast.Field]fieldDoc)
 	for _, f := range pkg.Files {
 		cmap := ast.NewCommentMap(fset, f, f.Comments)
 		for node, groups := range cmap {
@@ -492,14 +790,16 @@ func Package(path string, files []string, multiline bool) (*PkgInfo, error) {
 			if !ok || len(groups) == 0 {
 				continue
 			}
-			fieldComments[field] = strings.TrimSpace(groups[0].Text())
+			fieldComments[field] = parseFieldDoc(groups)
 		}
 	}
 
 	pi := &PkgInfo{
-		AstPkg:    pkg,
-		DocPkg:    p,
-		Multiline: multiline,
+		AstPkg:          pkg,
+		DocPkg:          p,
+		FileSet:         fset,
+		Multiline:       multiline,
+		NamespaceGroups: hasComment(pkg, namespaceGroupsTag),
 	}
 	if multiline {
 		pi.Description = strings.TrimSuffix(p.Doc, "\n")
@@ -507,8 +807,14 @@ func Package(path string, files []string, multiline bool) (*PkgInfo, error) {
 		pi.Description = oneLineDoc(p.Doc)
 	}
 
-	setNamespaces(pi, fieldComments)
-	setFuncs(pi, fieldComments)
+	decls := &declInfo{
+		fset:          fset,
+		fieldComments: fieldComments,
+		textTypes:     textUnmarshalers(p),
+		structs:       structTypes(p),
+	}
+	setNamespaces(pi, decls)
+	setFuncs(pi, decls)
 
 	hasDupes, names := checkDupeTargets(pi)
 	if hasDupes {
@@ -599,14 +905,14 @@ func (s Imports) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
-func setFuncs(pi *PkgInfo, fieldComments map[*ast.Field]string) {
+func setFuncs(pi *PkgInfo, decls *declInfo) {
 	for _, f := range pi.DocPkg.Funcs {
 		if f.Recv != "" {
 			debug.Printf("skipping method %s.%s", f.Recv, f.Name)
 			// skip methods
 			continue
 		}
-		fn, ok := funcFromDoc(f, pi.DocPkg.ImportPath, f.Name, pi.Multiline, fieldComments)
+		fn, ok := funcFromDoc(f, pi.DocPkg.ImportPath, f.Name, pi.Multiline, decls)
 		if !ok {
 			continue
 		}
@@ -614,43 +920,243 @@ func setFuncs(pi *PkgInfo, fieldComments map[*ast.Field]string) {
 	}
 }
 
-func setNamespaces(pi *PkgInfo, fieldComments map[*ast.Field]string) {
+func setNamespaces(pi *PkgInfo, decls *declInfo) {
 	for _, t := range pi.DocPkg.Types {
 		if !isNamespace(t) {
 			continue
 		}
 		debug.Printf("found namespace %s %s", pi.DocPkg.ImportPath, t.Name)
+		// a mage:group on the namespace is the group of all its targets.
+		td, _ := parseFuncDoc(t.Doc)
+		group := td.group
+		if group == "" && pi.NamespaceGroups {
+			group = t.Name
+		}
 		for _, f := range t.Methods {
-			fn, ok := funcFromDoc(f, pi.DocPkg.ImportPath, t.Name+"."+f.Name, pi.Multiline, fieldComments)
+			fn, ok := funcFromDoc(f, pi.DocPkg.ImportPath, t.Name+"."+f.Name, pi.Multiline, decls)
 			if !ok {
 				continue
 			}
 			fn.Receiver = t.Name
+			if fn.Group == "" {
+				fn.Group = group
+			}
 			pi.Funcs = append(pi.Funcs, fn)
 		}
 	}
 }
 
-func funcFromDoc(f *doc.Func, importpath, funcname string, multiline bool, fieldComments map[*ast.Field]string) (*Function, bool) {
+func funcFromDoc(f *doc.Func, importpath, funcname string, multiline bool, decls *declInfo) (*Function, bool) {
 	if !ast.IsExported(f.Name) {
 		return nil, false
 	}
-	fn, err := funcType(f.Decl.Type, fieldComments)
+	fd, err := parseFuncDoc(f.Doc)
+	if err != nil {
+		warnInvalid(decls, f, funcname, err)
+		return nil, false
+	}
+	if fd.internal {
+		// it's only for use with mg.Deps, so it needn't be a valid target.
+		debug.Printf("skipping internal method %s %s", importpath, funcname)
+		return nil, false
+	}
+	fn, err := funcType(f.Decl.Type, decls)
+	if err == nil {
+		if err = fn.setDefaults(fd.defaults); err != nil {
+			err = annotationError{err}
+		}
+	}
+	var ae annotationError
+	if errors.As(err, &ae) {
+		warnInvalid(decls, f, funcname, err)
+		return nil, false
+	}
 	if err != nil {
 		debug.Printf("skipping invalid method %s %s: %v", importpath, funcname, err)
 		return nil, false
 	}
 	debug.Printf("found method %s %s", importpath, funcname)
 	fn.Name = f.Name
+	pos := decls.fset.Position(f.Decl.Name.Pos())
+	fn.File, fn.Line, fn.Column = pos.Filename, pos.Line, pos.Column
+	fn.Hidden = fd.hidden
+	fn.Group = fd.group
+	fn.Deprecated = fd.deprecated
+	fn.Watch = fd.watch
 	if multiline {
-		fn.Comment = strings.TrimSuffix(f.Doc, "\n")
+		fn.Comment = strings.TrimSuffix(fd.text, "\n")
 	} else {
-		fn.Comment = oneLineDoc(f.Doc)
+		fn.Comment = oneLineDoc(fd.text)
 	}
-	fn.Synopsis = sanitizeSynopsis(f)
+	withoutTags := *f
+	withoutTags.Doc = fd.text
+	fn.Synopsis = sanitizeSynopsis(&withoutTags)
 	return fn, true
 }
 
+// annotationError is an error in the mage annotations or struct tags of a
+// function. Unlike an unsupported signature, which just means that a function
+// isn't a target, it is reported with a warning, since the function was meant
+// to be one.
+type annotationError struct {
+	error
+}
+
+func (e annotationError) Unwrap() error {
+	return e.error
+}
+
+// warnInvalid warns that the function isn't a target because of an error in
+// its annotations, which would otherwise only show as an unknown target.
+func warnInvalid(decls *declInfo, f *doc.Func, funcname string, err error) {
+	log.Printf("%s: warning: %s is not a target: %v", decls.fset.Position(f.Decl.Name.Pos()), funcname, err)
+}
+
+// funcDoc is the doc comment of a target, with its annotations parsed.
+type funcDoc struct {
+	text       string            // the doc comment without the annotations
+	defaults   map[string]string // mage:default values by argument name
+	hidden     bool              // the function is annotated with mage:hidden
+	internal   bool              // the function is annotated with mage:internal
+	group      string            // the group named by a mage:group annotation
+	deprecated string            // the text of the Deprecated: paragraph
+	watch      []string          // the globs of mage:watch annotations
+}
+
+// parseFuncDoc parses the doc comment of a target. Lines of the form
+// "mage:default name=value" give the default of an optional argument, a
+// mage:hidden line leaves the target out of listings, a mage:internal line
+// means the function isn't a target at all, a "mage:group name" line lists
+// the target under a heading, and "mage:watch glob..." lines name the files
+// mage -watch runs the target again for. They are left out of the comment, and
+// so is a paragraph starting with "Deprecated:".
+func parseFuncDoc(text string) (funcDoc, error) {
+	fd := funcDoc{defaults: map[string]string{}}
+	var lines []string
+	for _, line := range strings.SplitAfter(text, "\n") {
+		tag, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToLower(tag) {
+		case hiddenTag:
+			fd.hidden = true
+			continue
+		case internalTag:
+			fd.internal = true
+			continue
+		case groupTag:
+			fd.group = strings.TrimSpace(rest)
+			continue
+		case watchTag:
+			fd.watch = append(fd.watch, strings.Fields(rest)...)
+			continue
+		case defaultTag:
+		default:
+			lines = append(lines, line)
+			continue
+		}
+		name, val, ok := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fd, fmt.Errorf("%s must be given as name=value, got %q", defaultTag, rest)
+		}
+		fd.defaults[strings.ToLower(name)] = strings.TrimSpace(val)
+	}
+
+	// like in Go, a paragraph starting with "Deprecated:" says the target is
+	// deprecated and what to use instead.
+	var paras []string
+	for _, p := range strings.Split(strings.Join(lines, ""), "\n\n") {
+		p = strings.Trim(p, "\n")
+		if p == "" {
+			continue
+		}
+		if strings.HasPrefix(p, deprecatedPrefix) {
+			fd.deprecated = strings.Join(strings.Fields(strings.TrimPrefix(p, deprecatedPrefix)), " ")
+			continue
+		}
+		paras = append(paras, p)
+	}
+	if len(paras) > 0 {
+		fd.text = strings.Join(paras, "\n\n") + "\n"
+	}
+	return fd, nil
+}
+
+// setDefaults sets the defaults of the optional arguments of the function from
+// mage:default annotations, keyed by lowercase name.
+func (f *Function) setDefaults(defaults map[string]string) error {
+	for name, val := range defaults {
+		found := false
+		for i := range f.Args {
+			a := &f.Args[i]
+			for j := range a.Fields {
+				if strings.ToLower(a.Fields[j].Name) == name {
+					a.Fields[j].Default, found = val, true
+				}
+			}
+			if strings.ToLower(a.Name) != name {
+				continue
+			}
+			if !a.Optional || a.Fields != nil {
+				return fmt.Errorf("%s given for %s, which is not an optional argument", defaultTag, a.Name)
+			}
+			a.Default, found = val, true
+		}
+		if !found {
+			return fmt.Errorf("%s given for unknown argument %s", defaultTag, name)
+		}
+	}
+	for _, a := range f.OptionalArgs() {
+		if err := checkDefault(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDefault returns an error if the default of an argument isn't a valid
+// value for it, so that it isn't left to fail every run of the target.
+func checkDefault(a Arg) error {
+	if a.Default == "" || a.TextUnmarshaler {
+		return nil
+	}
+	vals := []string{a.Default}
+	typ := a.Type
+	if strings.HasPrefix(typ, "[]") {
+		vals = strings.Split(a.Default, ",")
+		typ = typ[2:]
+	}
+	for _, v := range vals {
+		var err error
+		switch typ {
+		case "int":
+			_, err = strconv.Atoi(v)
+		case "float64":
+			_, err = strconv.ParseFloat(v, 64)
+		case "bool":
+			_, err = strconv.ParseBool(v)
+		case "time.Duration":
+			_, err = time.ParseDuration(v)
+		case "string":
+			if len(a.Choices) > 0 && !contains(a.Choices, v) {
+				err = fmt.Errorf("must be one of: %s", strings.Join(a.Choices, ", "))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid default %q for %s: %v", a.Default, a.Name, err)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func setImports(gocmd string, pi *PkgInfo) error {
 	importNames := map[string]string{}
 	rootImports := []string{}
@@ -802,7 +1308,7 @@ func checkDupeTargets(info *PkgInfo) (hasDupes bool, names map[string][]string)
 			hasDupes = true
 		}
 		lowers[low] = true
-		names[low] = append(names[low], f.Name)
+		names[low] = append(names[low], withPosition(f.Name, f))
 	}
 	return hasDupes, names
 }
@@ -839,16 +1345,18 @@ func setDefault(pi *PkgInfo) {
 			if !ok {
 				continue
 			}
+			pos := pi.position(spec.Names[0].Pos())
 			if len(spec.Values) != 1 {
-				log.Println("warning: default declaration has multiple values")
+				log.Printf("%s: warning: default declaration has multiple values", pos)
 			}
 
 			f, err := getFunction(spec.Values[0], pi)
 			if err != nil {
-				log.Println("warning: default declaration malformed:", err)
+				log.Printf("%s: warning: default declaration malformed: %v", pos, err)
 				return
 			}
 			pi.DefaultFunc = f
+			pi.DefaultPosition = pos
 			return
 		}
 	}
@@ -873,43 +1381,75 @@ func setAliases(pi *PkgInfo) {
 				return
 			}
 			if len(spec.Values) != 1 {
-				log.Println("warning: aliases declaration has multiple values")
+				log.Printf("%s: warning: aliases declaration has multiple values", pi.position(spec.Pos()))
 			}
 			comp, ok := spec.Values[0].(*ast.CompositeLit)
 			if !ok {
-				log.Println("warning: aliases declaration is not a map")
+				log.Printf("%s: warning: aliases declaration is not a map", pi.position(spec.Pos()))
 				return
 			}
 			pi.Aliases = map[string]*Function{}
+			pi.AliasPositions = map[string]token.Position{}
 			for _, elem := range comp.Elts {
+				pos := pi.position(elem.Pos())
 				kv, ok := elem.(*ast.KeyValueExpr)
 				if !ok {
-					log.Printf("warning: alias declaration %q is not a map element", elem)
+					log.Printf("%s: warning: alias declaration %q is not a map element", pos, elem)
 					continue
 				}
 				k, ok := kv.Key.(*ast.BasicLit)
 				if !ok || k.Kind != token.STRING {
-					log.Printf("warning: alias key is not a string literal %q", elem)
+					log.Printf("%s: warning: alias key is not a string literal %q", pos, elem)
 					continue
 				}
 
 				alias, ok := lit2string(k)
 				if !ok {
-					log.Println("warning: malformed name for alias", elem)
+					log.Printf("%s: warning: malformed name for alias %v", pos, elem)
 					continue
 				}
 				f, err := getFunction(kv.Value, pi)
 				if err != nil {
-					log.Printf("warning, alias malformed: %v", err)
+					log.Printf("%s: warning, alias malformed: %v", pos, err)
 					continue
 				}
 				pi.Aliases[alias] = f
+				pi.AliasPositions[alias] = pos
 			}
 			return
 		}
 	}
 }
 
+// setHooks records whether the package declares a Hooks variable of type
+// mg.Hooks, which the generated mainfile runs around every target.
+func setHooks(pi *PkgInfo) {
+	for _, v := range pi.DocPkg.Vars {
+		for x, name := range v.Names {
+			if name != "Hooks" {
+				continue
+			}
+			spec, ok := v.Decl.Specs[x].(*ast.ValueSpec)
+			if !ok {
+				log.Println("warning: hooks declaration is not a value")
+				return
+			}
+			typ := spec.Type
+			if typ == nil && len(spec.Values) == 1 {
+				if comp, ok := spec.Values[0].(*ast.CompositeLit); ok {
+					typ = comp.Type
+				}
+			}
+			if sel, ok := typ.(*ast.SelectorExpr); !ok || sel.Sel.Name != "Hooks" {
+				log.Println("warning: hooks declaration is not an mg.Hooks value")
+				return
+			}
+			pi.HasHooks = true
+			return
+		}
+	}
+}
+
 func getFunction(exp ast.Expr, pi *PkgInfo) (*Function, error) {
 	// selector expressions are in LIFO format.
 	// So, in  foo.bar.baz the first selector.Name is
@@ -1074,7 +1614,7 @@ func hasErrorReturn(ft *ast.FuncType) (bool, error) {
 	return false, errors.New("EBADRETURNTYPE")
 }
 
-func funcType(ft *ast.FuncType, fieldComments map[*ast.Field]string) (*Function, error) {
+func funcType(ft *ast.FuncType, decls *declInfo) (*Function, error) {
 	var err error
 	f := &Function{}
 	f.IsContext, err = hasContextParam(ft)
@@ -1092,6 +1632,21 @@ func funcType(ft *ast.FuncType, fieldComments map[*ast.Field]string) (*Function,
 	}
 	for ; x < len(ft.Params.List); x++ {
 		param := ft.Params.List[x]
+		fd := decls.fieldComments[param]
+		if id, ok := param.Type.(*ast.Ident); ok && decls.structs[id.Name] != nil && !decls.textTypes[id.Name] {
+			if len(param.Names) != 1 {
+				return nil, fmt.Errorf("only one options struct argument is supported, got %d of type %s", len(param.Names), id.Name)
+			}
+			fields, err := decls.options(decls.structs[id.Name])
+			if err != nil {
+				return nil, fmt.Errorf("invalid options struct %s: %w", id.Name, err)
+			}
+			if fd.env != "" {
+				return nil, annotationError{fmt.Errorf("%s is not supported on options structs, only on their fields", envTag)}
+			}
+			f.Args = append(f.Args, Arg{Name: param.Names[0].Name, Type: id.Name, Optional: true, Comment: fd.comment, Fields: fields})
+			continue
+		}
 		optional := false
 		paramType := param.Type
 		// Check for pointer types (optional arguments)
@@ -1099,23 +1654,243 @@ func funcType(ft *ast.FuncType, fieldComments map[*ast.Field]string) (*Function,
 			optional = true
 			paramType = star.X
 		}
-		t := fmt.Sprint(paramType)
-		typ, ok := argTypes[t]
+		typ, text, ok := decls.argType(paramType)
 		if !ok {
 			if optional {
-				return nil, fmt.Errorf("unsupported argument type: *%s", t)
+				return nil, fmt.Errorf("unsupported argument type: *%s", typ)
 			}
-			return nil, fmt.Errorf("unsupported argument type: %s", t)
+			return nil, fmt.Errorf("unsupported argument type: %s", typ)
+		}
+		if len(fd.choices) > 0 && typ != "string" {
+			return nil, annotationError{fmt.Errorf("%s is only supported on string arguments, not %s", enumTag, typ)}
+		}
+		if err := checkChoices(fd.choices); err != nil {
+			return nil, err
+		}
+		if fd.env != "" && !optional {
+			return nil, annotationError{fmt.Errorf("%s is only supported on optional arguments", envTag)}
 		}
-		comment := fieldComments[param]
 		// support for foo, bar string
 		for _, name := range param.Names {
-			f.Args = append(f.Args, Arg{Name: name.Name, Type: typ, Optional: optional, Comment: comment})
+			f.Args = append(f.Args, Arg{Name: name.Name, Type: typ, Optional: optional, Comment: fd.comment, TextUnmarshaler: text, Choices: fd.choices, Env: fd.env})
+		}
+	}
+
+	structs := 0
+	names := map[string]bool{}
+	for _, a := range f.Args {
+		if a.Fields != nil {
+			structs++
 		}
 	}
+	if structs > 1 {
+		return nil, errors.New("only one options struct argument is supported")
+	}
+	for _, a := range f.OptionalArgs() {
+		name := strings.ToLower(a.Name)
+		if names[name] {
+			return nil, fmt.Errorf("duplicate option %q", a.Name)
+		}
+		names[name] = true
+	}
 	return f, nil
 }
 
+// declInfo holds what funcType needs to know about the declarations of the
+// package to check the arguments of targets.
+type declInfo struct {
+	fset          *token.FileSet
+	fieldComments map[*ast.Field]fieldDoc
+	textTypes     map[string]bool
+	structs       map[string]*ast.StructType
+}
+
+// argType returns the logical type name of an argument or option of type t,
+// and whether it implements encoding.TextUnmarshaler. If the type isn't
+// supported, ok is false and typ is its name for error messages.
+func (d *declInfo) argType(t ast.Expr) (typ string, text, ok bool) {
+	name := typeName(t)
+	if typ, ok := argTypes[name]; ok {
+		return typ, false, true
+	}
+	if d.textTypes[name] {
+		return name, true, true
+	}
+	return name, false, false
+}
+
+// options returns the options for the exported fields of an options struct.
+// A default:"value" tag on a field gives the value of an option that isn't
+// set, and an env:"NAME" tag the environment variable it is read from then,
+// like a mage:env comment.
+func (d *declInfo) options(st *ast.StructType) ([]Arg, error) {
+	var opts []Arg
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return nil, annotationError{fmt.Errorf("embedded field %s is not supported", types.ExprString(field.Type))}
+		}
+		fd := d.fieldComments[field]
+		var def string
+		if field.Tag != nil {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, annotationError{fmt.Errorf("malformed tag of field %s: %v", field.Names[0].Name, err)}
+			}
+			def = reflect.StructTag(tag).Get("default")
+			if env := reflect.StructTag(tag).Get("env"); env != "" {
+				fd.env = env
+			}
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			typ, text, ok := d.argType(field.Type)
+			if !ok {
+				return nil, annotationError{fmt.Errorf("unsupported type %s of field %s", types.ExprString(field.Type), name.Name)}
+			}
+			if len(fd.choices) > 0 && typ != "string" {
+				return nil, annotationError{fmt.Errorf("%s is only supported on string fields, not %s", enumTag, typ)}
+			}
+			if err := checkChoices(fd.choices); err != nil {
+				return nil, err
+			}
+			opts = append(opts, Arg{
+				Name:            optionName(name.Name),
+				Type:            typ,
+				Optional:        true,
+				Comment:         fd.comment,
+				TextUnmarshaler: text,
+				Choices:         fd.choices,
+				Default:         def,
+				Env:             fd.env,
+				Field:           name.Name,
+			})
+		}
+	}
+	if len(opts) == 0 {
+		return nil, errors.New("no exported fields")
+	}
+	return opts, nil
+}
+
+// optionName returns the name of the option for a struct field, with its
+// leading capitals lowered like in a Go variable name: DryRun is dryRun, and
+// DBHost is dbHost.
+func optionName(field string) string {
+	r := []rune(field)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) {
+		// keep the capital that starts the next word.
+		n--
+	}
+	for i := 0; i < n; i++ {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// structTypes returns the struct types declared in the package, by name.
+func structTypes(p *doc.Package) map[string]*ast.StructType {
+	structs := map[string]*ast.StructType{}
+	for _, t := range p.Types {
+		for _, spec := range t.Decl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != t.Name {
+				continue
+			}
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[t.Name] = st
+			}
+		}
+	}
+	return structs
+}
+
+// fieldDoc is the documentation of a function parameter.
+type fieldDoc struct {
+	comment string
+	choices []string // the values allowed by a mage:enum annotation
+	env     string   // the environment variable named by a mage:env annotation
+}
+
+// parseFieldDoc parses the comments on a parameter. Lines starting with
+// mage:enum list the values allowed for the parameter, separated by spaces or
+// commas, and a mage:env line names the environment variable the parameter is
+// read from when it isn't given. Both are left out of the comment.
+func parseFieldDoc(groups []*ast.CommentGroup) fieldDoc {
+	var fd fieldDoc
+	for _, g := range groups {
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(g.Text()), "\n") {
+			vals := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+			if len(vals) > 0 && strings.ToLower(vals[0]) == enumTag {
+				fd.choices = append(fd.choices, vals[1:]...)
+				continue
+			}
+			if len(vals) == 2 && strings.ToLower(vals[0]) == envTag {
+				fd.env = vals[1]
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if fd.comment == "" {
+			fd.comment = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+	}
+	return fd
+}
+
+// checkChoices returns an error if mage:enum lists a value more than once,
+// which would make the generated code fail to compile.
+func checkChoices(choices []string) error {
+	seen := map[string]bool{}
+	for _, c := range choices {
+		if seen[c] {
+			return annotationError{fmt.Errorf("%s lists %q more than once", enumTag, c)}
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// textUnmarshalers returns the names of the types declared in the package that
+// implement encoding.TextUnmarshaler with a pointer receiver. The mainfile
+// unmarshals into a variable of the type, so with a value receiver the parsed
+// value would be lost. Types from other packages can't be used as arguments,
+// since the mainfile would have to import them.
+func textUnmarshalers(p *doc.Package) map[string]bool {
+	types := map[string]bool{}
+	for _, t := range p.Types {
+		for _, m := range t.Methods {
+			if m.Name != "UnmarshalText" {
+				continue
+			}
+			if _, ok := m.Decl.Recv.List[0].Type.(*ast.StarExpr); !ok {
+				continue
+			}
+			ft := m.Decl.Type
+			if ft.Params.NumFields() == 1 && ft.Results.NumFields() == 1 &&
+				typeName(ft.Params.List[0].Type) == "[]byte" && fmt.Sprint(ft.Results.List[0].Type) == "error" {
+				types[t.Name] = true
+			}
+		}
+	}
+	return types
+}
+
+// typeName returns the name of a type as it is looked up in argTypes, with
+// slices written as []elem.
+func typeName(t ast.Expr) string {
+	if arr, ok := t.(*ast.ArrayType); ok && arr.Len == nil {
+		return "[]" + fmt.Sprint(arr.Elt)
+	}
+	return fmt.Sprint(t)
+}
+
 // sanitizeDocComment sanitizes a doc comment by replacing characters that would screw up formatting
 // in the output file.
 func sanitizeDocComment(s string) string {
@@ -1147,17 +1922,52 @@ func hasComment(pkg *ast.Package, tag string) bool {
 }
 
 var argTypes = map[string]string{
-	"string":           "string",
-	"int":              "int",
-	"float64":          "float64",
-	"&{time Duration}": "time.Duration",
-	"bool":             "bool",
+	"string":             "string",
+	"int":                "int",
+	"float64":            "float64",
+	"&{time Duration}":   "time.Duration",
+	"bool":               "bool",
+	"[]string":           "[]string",
+	"[]int":              "[]int",
+	"[]float64":          "[]float64",
+	"[]&{time Duration}": "[]time.Duration",
+	"[]bool":             "[]bool",
 }
 
 // genType converts a logical type name to the type name used in generated code.
 func genType(typ string) string {
-	if typ == "time.Duration" {
-		return "_time.Duration"
-	}
-	return typ
+	return strings.Replace(typ, "time.Duration", "_time.Duration", 1)
+}
+
+// parseFuncs holds the code that parses a string into each of the argument
+// types, other than string itself and slices.
+var parseFuncs = map[string]string{
+	"int":           "_strconv.Atoi(%s)",
+	"float64":       "_strconv.ParseFloat(%s, 64)",
+	"bool":          "_strconv.ParseBool(%s)",
+	"time.Duration": "_time.ParseDuration(%s)",
+}
+
+// sliceCode returns code that splits the comma separated list in src and
+// appends its items to dst, a slice of the given type. If an item can't be
+// parsed, the code runs onErr.
+func sliceCode(typ, src, dst, onErr string) string {
+	f, ok := parseFuncs[strings.TrimPrefix(typ, "[]")]
+	if !ok {
+		return fmt.Sprintf(`
+				if %[1]s != "" {
+					%[2]s = append(%[2]s, _strings.Split(%[1]s, ",")...)
+				}`, src, dst)
+	}
+	return fmt.Sprintf(`
+				if %[1]s != "" {
+					for _, _s := range _strings.Split(%[1]s, ",") {
+						_item, err := %[2]s
+						if err != nil {
+							%[3]s
+							_os.Exit(2)
+						}
+						%[4]s = append(%[4]s, _item)
+					}
+				}`, src, fmt.Sprintf(f, "_s"), onErr, dst)
 }
@@ -1,11 +1,13 @@
 package parse
 
 import (
+	"bytes"
 	"go/ast"
 	"go/doc"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/magefile/mage/internal"
@@ -24,41 +26,65 @@ func TestParse(t *testing.T) {
 	expected := []Function{
 		{
 			Name:     "ReturnsNilError",
+			File:     "testdata/func.go",
+			Line:     10,
+			Column:   6,
 			IsError:  true,
 			Comment:  "Synopsis for \"returns\" error. And some more text.",
 			Synopsis: `Synopsis for "returns" error.`,
 		},
 		{
-			Name: "ReturnsVoid",
+			Name:   "ReturnsVoid",
+			File:   "testdata/command.go",
+			Line:   22,
+			Column: 6,
 		},
 		{
 			Name:      "TakesContextReturnsError",
+			File:      "testdata/command.go",
+			Line:      32,
+			Column:    6,
 			IsError:   true,
 			IsContext: true,
 		},
 		{
 			Name:      "TakesContextReturnsVoid",
+			File:      "testdata/command.go",
+			Line:      28,
+			Column:    6,
 			IsError:   false,
 			IsContext: true,
 		},
 		{
 			Name:     "RepeatingSynopsis",
+			File:     "testdata/repeating_synopsis.go",
+			Line:     8,
+			Column:   6,
 			IsError:  true,
 			Comment:  "RepeatingSynopsis chops off the repeating function name. Some more text.",
 			Synopsis: "chops off the repeating function name.",
 		},
 		{
 			Name:     "Foobar",
+			File:     "testdata/subcommands.go",
+			Line:     10,
+			Column:   14,
 			Receiver: "Build",
 			IsError:  true,
 		},
 		{
 			Name:     "Baz",
+			File:     "testdata/subcommands.go",
+			Line:     15,
+			Column:   14,
 			Receiver: "Build",
 			IsError:  false,
 		},
 		{
 			Name:     "WithBackticks",
+			File:     "testdata/func.go",
+			Line:     18,
+			Column:   6,
 			IsError:  false,
 			Comment:  "WithBackticks has a synopsis that includes 'backticks' which were a problem once.",
 			Synopsis: "has a synopsis that includes 'backticks' which were a problem once.",
@@ -95,6 +121,13 @@ func TestParse(t *testing.T) {
 		t.Fatalf("expected to only have two aliases, but have %#v", info.Aliases)
 	}
 
+	if pos := info.DefaultPosition.String(); pos != "testdata/command.go:14:5" {
+		t.Fatalf("expected default at testdata/command.go:14:5, but got %s", pos)
+	}
+	if pos := info.AliasPositions["baz"].String(); pos != "testdata/alias.go:8:2" {
+		t.Fatalf("expected alias baz at testdata/alias.go:8:2, but got %s", pos)
+	}
+
 	for _, fn := range expected {
 		found := false
 		for _, infoFn := range info.Funcs {
@@ -287,14 +320,20 @@ func TestOptionalArgs(t *testing.T) {
 
 	expected := []Function{
 		{
-			Name: "AllOptional",
+			Name:   "AllOptional",
+			File:   "testdata/optargs.go",
+			Line:   18,
+			Column: 6,
 			Args: []Arg{
 				{Name: "a", Type: "string", Optional: true},
 				{Name: "b", Type: "int", Optional: true},
 			},
 		},
 		{
-			Name: "FlagDocFunc",
+			Name:   "FlagDocFunc",
+			File:   "testdata/optargs.go",
+			Line:   22,
+			Column: 6,
 			Args: []Arg{
 				{Name: "name", Type: "string"},
 				{Name: "greeting", Type: "string", Optional: true, Comment: "the greeting message"},
@@ -302,39 +341,65 @@ func TestOptionalArgs(t *testing.T) {
 			},
 		},
 		{
-			Name: "OptionalBool",
+			Name:   "OptionalBool",
+			File:   "testdata/optargs.go",
+			Line:   14,
+			Column: 6,
 			Args: []Arg{
 				{Name: "verbose", Type: "bool", Optional: true},
 			},
 		},
 		{
-			Name: "OptionalDuration",
+			Name:   "OptionalDuration",
+			File:   "testdata/optargs.go",
+			Line:   16,
+			Column: 6,
 			Args: []Arg{
 				{Name: "base", Type: "time.Duration"},
 				{Name: "extra", Type: "time.Duration", Optional: true},
 			},
 		},
 		{
-			Name: "OptionalFloat64",
+			Name:   "OptionalFloat64",
+			File:   "testdata/optargs.go",
+			Line:   12,
+			Column: 6,
 			Args: []Arg{
 				{Name: "value", Type: "float64"},
 				{Name: "factor", Type: "float64", Optional: true},
 			},
 		},
 		{
-			Name: "OptionalInt",
+			Name:   "OptionalInt",
+			File:   "testdata/optargs.go",
+			Line:   10,
+			Column: 6,
 			Args: []Arg{
 				{Name: "a", Type: "int"},
 				{Name: "b", Type: "int", Optional: true},
 			},
 		},
 		{
-			Name: "OptionalString",
+			Name:   "OptionalString",
+			File:   "testdata/optargs.go",
+			Line:   8,
+			Column: 6,
 			Args: []Arg{
 				{Name: "name", Type: "string"},
 				{Name: "greeting", Type: "string", Optional: true},
 			},
 		},
+		{
+			Name:   "Slices",
+			File:   "testdata/optargs.go",
+			Line:   20,
+			Column: 6,
+			Args: []Arg{
+				{Name: "pkgs", Type: "[]string"},
+				{Name: "counts", Type: "[]int", Optional: true},
+				{Name: "waits", Type: "[]time.Duration", Optional: true},
+			},
+		},
 	}
 
 	if len(info.Funcs) != len(expected) {
@@ -357,3 +422,273 @@ func TestOptionalArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestHooks(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"func.go", "hooks.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.HasHooks {
+		t.Fatal("expected hooks to be found")
+	}
+
+	info, err = PrimaryPackage("go", "./testdata", []string{"func.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.HasHooks {
+		t.Fatal("expected no hooks")
+	}
+}
+
+func TestTextUnmarshalerArgs(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"textargs.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Funcs) != 1 {
+		t.Fatalf("expected only Build to be a target, since UnmarshalText must have the right signature and a pointer receiver, but got %v", info.Funcs)
+	}
+	fn := info.Funcs[0]
+	expected := []Arg{
+		{Name: "platform", Type: "Platform", TextUnmarshaler: true},
+		{Name: "extra", Type: "Platform", Optional: true, TextUnmarshaler: true},
+	}
+	if !reflect.DeepEqual(fn.Args, expected) {
+		t.Fatalf("expected args %#v, but got %#v", expected, fn.Args)
+	}
+	if !strings.Contains(fn.ExecCode(), "var arg0 Platform\n") {
+		t.Errorf("expected the argument to be declared with its type, but got:\n%s", fn.ExecCode())
+	}
+
+	// targets from imported magefiles need the type qualified with the package.
+	fn.Package = "build"
+	code := fn.ExecCode()
+	for _, s := range []string{"var arg0 build.Platform\n", "var arg1 *build.Platform", "arg0.UnmarshalText([]byte(args.Args[x]))"} {
+		if !strings.Contains(code, s) {
+			t.Errorf("expected code to contain %q, but got:\n%s", s, code)
+		}
+	}
+}
+
+func TestEnumArgs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	info, err := PrimaryPackage("go", "./testdata", []string{"enum.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Funcs) != 1 {
+		t.Fatalf("expected only Deploy to be a target, since mage:enum is only for strings and can't repeat values, but got %v", info.Funcs)
+	}
+	warning := `testdata/enum.go:19:6: warning: Mode is not a target: mage:enum lists "fast" more than once`
+	if !strings.Contains(buf.String(), warning) {
+		t.Errorf("expected log output to contain %q, but got %q", warning, buf.String())
+	}
+	expected := []Arg{
+		{Name: "env", Type: "string", Choices: []string{"dev", "staging", "prod"}},
+		{Name: "region", Type: "string", Optional: true, Comment: "the region to deploy to", Choices: []string{"us", "eu"}},
+	}
+	if !reflect.DeepEqual(info.Funcs[0].Args, expected) {
+		t.Fatalf("expected args %#v, but got %#v", expected, info.Funcs[0].Args)
+	}
+}
+
+func TestOptionsStruct(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	info, err := PrimaryPackage("go", "./testdata", []string{"options.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"testdata/options.go:22:6: warning: Bad is not a target: invalid options struct Nested: unsupported type DeployOptions of field Opts",
+		"testdata/options.go:32:6: warning: Pointer is not a target: invalid options struct PointerOptions: unsupported type *int of field N",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected log output to contain %q, but got %q", expected, buf.String())
+		}
+	}
+	if len(info.Funcs) != 1 {
+		t.Fatalf("expected only Deploy to be a target, but got %v", info.Funcs)
+	}
+	fn := info.Funcs[0]
+	expected := []Arg{
+		{Name: "env", Type: "string"},
+		{Name: "opts", Type: "DeployOptions", Optional: true, Fields: []Arg{
+			{Name: "dbHost", Type: "string", Optional: true, Comment: "the database to use", Field: "DBHost"},
+			{Name: "replicas", Type: "int", Optional: true, Default: "3", Field: "Replicas"},
+			{Name: "url", Type: "string", Optional: true, Env: "DEPLOY_URL", Field: "URL"},
+			{Name: "mode", Type: "string", Optional: true, Choices: []string{"fast", "safe"}, Field: "Mode"},
+		}},
+	}
+	if !reflect.DeepEqual(fn.Args, expected) {
+		t.Fatalf("expected args %#v, but got %#v", expected, fn.Args)
+	}
+	code := fn.ExecCode()
+	for _, s := range []string{"var arg1 DeployOptions\n", "arg1.DBHost = _tmp", `if !_seen["replicas"]`} {
+		if !strings.Contains(code, s) {
+			t.Errorf("expected code to contain %q, but got:\n%s", s, code)
+		}
+	}
+}
+
+func TestArgDefaults(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"defaults.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Funcs) != 1 {
+		t.Fatalf("expected only Greet to be a target, since the other defaults are invalid, but got %v", info.Funcs)
+	}
+	fn := info.Funcs[0]
+	expected := []Arg{
+		{Name: "name", Type: "string"},
+		{Name: "count", Type: "int", Optional: true, Default: "3"},
+		{Name: "mode", Type: "string", Optional: true, Default: "fast", Env: "GREET_MODE"},
+	}
+	if !reflect.DeepEqual(fn.Args, expected) {
+		t.Fatalf("expected args %#v, but got %#v", expected, fn.Args)
+	}
+	if fn.Comment != "Greet greets." || fn.Synopsis != "greets." {
+		t.Errorf("expected the annotations to be left out of the docs, but got comment %q and synopsis %q", fn.Comment, fn.Synopsis)
+	}
+}
+
+func TestInvalidAnnotationWarnings(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if _, err := PrimaryPackage("go", "./testdata", []string{"defaults.go"}, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"testdata/defaults.go:16:6: warning: RequiredEnv is not a target: mage:env is only supported on optional arguments",
+		"testdata/defaults.go:22:6: warning: Required is not a target: mage:default given for name, which is not an optional argument",
+		`testdata/defaults.go:26:6: warning: BadInt is not a target: invalid default "many" for count:`,
+		`testdata/defaults.go:30:6: warning: BadEnum is not a target: invalid default "mars" for region: must be one of: us, eu`,
+		"testdata/defaults.go:36:6: warning: Unknown is not a target: mage:default given for unknown argument missing",
+		`testdata/defaults.go:40:6: warning: Malformed is not a target: mage:default must be given as name=value, got "count"`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected log output to contain %q, but got %q", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Helper") {
+		t.Errorf("expected no warning for a function with an unsupported signature, but got %q", buf.String())
+	}
+}
+
+func TestHiddenAndInternal(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"hidden.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Funcs) != 1 {
+		t.Fatalf("expected only Debug to be a target, but got %v", info.Funcs)
+	}
+	fn := info.Funcs[0]
+	if !fn.Hidden {
+		t.Error("expected Debug to be hidden")
+	}
+	if fn.Comment != "Debug prints debugging information." {
+		t.Errorf("expected the annotation to be left out of the comment, but got %q", fn.Comment)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"groups.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Tag":               "Release",
+		"Docs:Serve":        "Docs",
+		"Deploy:Staging":    "Release",
+		"Deploy:Production": "Danger zone",
+	}
+	actual := map[string]string{}
+	for _, f := range info.Funcs {
+		actual[f.TargetName()] = f.Group
+		if f.Name == "Tag" && f.Comment != "Tag tags a release." {
+			t.Errorf("expected the annotation to be left out of the comment, but got %q", f.Comment)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected groups %v, but got %v", expected, actual)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"deprecated.go"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := info.Funcs[0]
+	if fn.Deprecated != "Use build instead." {
+		t.Errorf("expected the deprecation to be parsed, but got %q", fn.Deprecated)
+	}
+	if fn.Comment != "Compile compiles the project." {
+		t.Errorf("expected the deprecation to be left out of the comment, but got %q", fn.Comment)
+	}
+	if fn.ListSynopsis() != "compiles the project. (deprecated)" {
+		t.Errorf("expected the synopsis to be marked, but got %q", fn.ListSynopsis())
+	}
+}
+
+func TestAliasWarningPosition(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	info, err := PrimaryPackage("go", "./testdata", []string{"badalias.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Aliases) != 1 || info.Aliases["b"] == nil {
+		t.Fatalf("expected only alias b, but have %#v", info.Aliases)
+	}
+	expected := "testdata/badalias.go:8:2: warning, alias malformed:"
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("expected log output to contain %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestNoFileSet(t *testing.T) {
+	info, err := Package("./testdata", []string{"func.go", "command.go", "alias.go", "repeating_synopsis.go", "subcommands.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a PkgInfo made some other way may have no FileSet.
+	info.FileSet = nil
+	setDefault(info)
+	setAliases(info)
+	if info.Aliases["baz"] == nil {
+		t.Fatalf("expected alias baz, but have %#v", info.Aliases)
+	}
+	if pos := info.AliasPositions["baz"]; pos.IsValid() {
+		t.Fatalf("expected no position without a FileSet, but got %s", pos)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	info, err := PrimaryPackage("go", "./testdata", []string{"watch.go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"Build": {"content/*.md", "layouts/*", "static"},
+		"Clean": nil,
+	}
+	actual := map[string][]string{}
+	for _, f := range info.Funcs {
+		actual[f.Name] = f.Watch
+		if f.Name == "Build" && f.Comment != "Build builds the site." {
+			t.Errorf("expected the annotations to be left out of the comment, but got %q", f.Comment)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected globs %v, but got %v", expected, actual)
+	}
+}
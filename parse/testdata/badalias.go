@@ -0,0 +1,13 @@
+//go:build mage
+// +build mage
+
+package main
+
+var Aliases = map[string]interface{}{
+	"b":  Build,
+	"co": checkout,
+}
+
+func Build() {}
+
+func checkout() {}
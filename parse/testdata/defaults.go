@@ -0,0 +1,45 @@
+//go:build mage
+// +build mage
+
+package main
+
+// Greet greets.
+// mage:default count=3
+// mage:default mode=fast
+func Greet(
+	name string,
+	count *int,
+	mode *string, // mage:env GREET_MODE
+) {
+}
+
+func RequiredEnv(
+	name string, // mage:env NAME
+) {
+}
+
+// mage:default name=bob
+func Required(name string) {
+}
+
+// mage:default count=many
+func BadInt(count *int) {
+}
+
+// mage:default region=mars
+func BadEnum(
+	region *string, // mage:enum us eu
+) {
+}
+
+// mage:default missing=1
+func Unknown(count *int) {
+}
+
+// mage:default count
+func Malformed(count *int) {
+}
+
+// Helper isn't a target, since its argument type isn't supported.
+func Helper(ch chan int) {
+}
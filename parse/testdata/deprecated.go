@@ -0,0 +1,11 @@
+//go:build mage
+// +build mage
+
+package main
+
+// Compile compiles the project.
+//
+// Deprecated: Use build
+// instead.
+func Compile() {
+}
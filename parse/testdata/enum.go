@@ -0,0 +1,22 @@
+//go:build mage
+// +build mage
+
+package main
+
+func Deploy(
+	env string, // mage:enum dev, staging, prod
+	// the region to deploy to
+	// mage:enum us eu
+	region *string,
+) {
+}
+
+func Scale(
+	n int, // mage:enum 1 2 3
+) {
+}
+
+func Mode(
+	mode string, // mage:enum fast fast safe
+) {
+}
@@ -0,0 +1,28 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "github.com/magefile/mage/mg"
+
+//mage:namespacegroups
+
+// Tag tags a release.
+// mage:group Release
+func Tag() {
+}
+
+type Docs mg.Namespace
+
+func (Docs) Serve() {
+}
+
+// mage:group Release
+type Deploy mg.Namespace
+
+func (Deploy) Staging() {
+}
+
+// mage:group Danger zone
+func (Deploy) Production() {
+}
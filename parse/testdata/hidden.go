@@ -0,0 +1,14 @@
+//go:build mage
+// +build mage
+
+package main
+
+// Debug prints debugging information.
+// mage:hidden
+func Debug() {
+}
+
+// Setup prepares the build.
+// mage:internal
+func Setup(n uint) {
+}
@@ -0,0 +1,8 @@
+//go:build mage
+// +build mage
+
+package main
+
+import "github.com/magefile/mage/mg"
+
+var Hooks = mg.Hooks{}
@@ -17,6 +17,8 @@ func OptionalDuration(base time.Duration, extra *time.Duration) {}
 
 func AllOptional(a *string, b *int) {}
 
+func Slices(pkgs []string, counts *[]int, waits *[]time.Duration) {}
+
 func FlagDocFunc(name string,
 	greeting *string, // the greeting message
 	count *int, // how many times
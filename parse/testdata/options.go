@@ -0,0 +1,33 @@
+//go:build mage
+// +build mage
+
+package main
+
+type DeployOptions struct {
+	DBHost   string // the database to use
+	Replicas int    `default:"3"`
+	URL      string `env:"DEPLOY_URL"`
+	// mage:enum fast safe
+	Mode    string
+	private bool
+}
+
+func Deploy(env string, opts DeployOptions) {
+}
+
+type Nested struct {
+	Opts DeployOptions
+}
+
+func Bad(n Nested) {
+}
+
+func Twice(a, b DeployOptions) {
+}
+
+type PointerOptions struct {
+	N *int
+}
+
+func Pointer(opts PointerOptions) {
+}
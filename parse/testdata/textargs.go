@@ -0,0 +1,28 @@
+//go:build mage
+// +build mage
+
+package main
+
+type Platform string
+
+func (p *Platform) UnmarshalText(text []byte) error {
+	*p = Platform(text)
+	return nil
+}
+
+// NotText has an UnmarshalText method with the wrong signature.
+type NotText string
+
+func (n *NotText) UnmarshalText(text string) error { return nil }
+
+// ValueText has an UnmarshalText method with a value receiver, which can't
+// set the value.
+type ValueText string
+
+func (v ValueText) UnmarshalText(text []byte) error { return nil }
+
+func Build(platform Platform, extra *Platform) {}
+
+func Bad(n NotText) {}
+
+func Lost(v ValueText) {}
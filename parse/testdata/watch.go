@@ -0,0 +1,14 @@
+//go:build mage
+// +build mage
+
+package main
+
+// Build builds the site.
+//
+// mage:watch content/*.md layouts/*
+// mage:watch static
+func Build() {
+}
+
+func Clean() {
+}
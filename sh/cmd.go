@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/magefile/mage/mg"
 )
@@ -144,7 +145,9 @@ func doRun(env map[string]string, stdout, stderr io.Writer, cmd string, args ...
 	if mg.Verbose() {
 		log.Println("exec:", cmd, strings.Join(quoted, " "))
 	}
+	start := time.Now()
 	err = c.Run()
+	mg.RecordCommand(cmd, args, start, ExitStatus(err), err)
 	return CmdRan(err), ExitStatus(err), err
 }
 
@@ -5,7 +5,11 @@
 package target
 
 import (
+	"fmt"
 	"os"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
 )
 
 // Path first expands environment variables like $FOO or ${FOO}, and then
@@ -15,6 +19,7 @@ import (
 // exist, it always returns true and nil. It's an error if any of the sources
 // don't exist.
 func Path(dst string, sources ...string) (bool, error) {
+	watch(expandEnv(sources)...)
 	stat, err := os.Stat(os.ExpandEnv(dst))
 	if os.IsNotExist(err) {
 		return true, nil
@@ -32,6 +37,7 @@ func Path(dst string, sources ...string) (bool, error) {
 // environment variables before globbing -- env var expansion happens during
 // the call to Path. It is an error for any glob to return an empty result.
 func Glob(dst string, globs ...string) (bool, error) {
+	watch(globs...)
 	stat, err := os.Stat(os.ExpandEnv(dst))
 	if os.IsNotExist(err) {
 		return true, nil
@@ -49,6 +55,7 @@ func Glob(dst string, globs ...string) (bool, error) {
 // file doesn't exist, it always returns true and nil.  It's an error if any
 // of the sources don't exist.
 func Dir(dst string, sources ...string) (bool, error) {
+	watch(expandEnv(sources)...)
 	dst = os.ExpandEnv(dst)
 	stat, err := os.Stat(dst)
 	if os.IsNotExist(err) {
@@ -66,3 +73,23 @@ func Dir(dst string, sources ...string) (bool, error) {
 	}
 	return DirNewer(destTime, sources...)
 }
+
+// watch records the sources of a target for mage -watch, which runs the
+// targets again when they change.
+func watch(sources ...string) {
+	path := os.Getenv(mg.WatchFileEnv)
+	if path == "" || len(sources) == 0 {
+		return
+	}
+	if err := internal.AppendWatch(path, sources...); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: can't record sources to watch:", err)
+	}
+}
+
+func expandEnv(sources []string) []string {
+	out := make([]string, len(sources))
+	for i, s := range sources {
+		out[i] = os.ExpandEnv(s)
+	}
+	return out
+}
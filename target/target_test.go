@@ -3,8 +3,12 @@ package target
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/magefile/mage/internal"
+	"github.com/magefile/mage/mg"
 )
 
 func TestPathMissingDest(t *testing.T) {
@@ -420,3 +424,32 @@ func TestDir(t *testing.T) {
 		})
 	}
 }
+
+func TestWatchRecordsSources(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "watch")
+	t.Setenv(mg.WatchFileEnv, watchFile)
+	t.Setenv("SRC_DIR", dir)
+	src := filepath.Join(dir, "source")
+	if err := os.WriteFile(src, []byte("hi!"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "missing")
+	if _, err := Path(dst, "$SRC_DIR/source"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Glob(dst, filepath.Join(dir, "*.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Dir(dst, dir, src); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := internal.ReadWatch(watchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{src, filepath.Join(dir, "*.txt"), dir}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected sources %q, but got %q", expected, actual)
+	}
+}